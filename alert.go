@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/google/uuid"
+)
+
+const (
+	AlertConditionDrop  = "drop"
+	AlertConditionAbove = "above"
+	AlertConditionBelow = "below"
+)
+
+var (
+	ErrUnknownAlertCondition = errors.New("unknown alert condition")
+	ErrMissingNomination     = errors.New("portfolio alerts need a nomination")
+)
+
+// AlertRule triggers a notification when the value of an ISIN, or the
+// portfolio total in a nomination when ISIN is empty, meets the condition;
+// Active keeps track of whether the condition was already met, so the alert
+// fires only once per crossing
+type AlertRule struct {
+	UUID       uuid.UUID `storm:"id"`
+	ISIN       string    `storm:"index"`
+	Nomination string
+	Condition  string
+	Threshold  float64
+	Notifier   string
+	Target     string
+
+	Active    bool
+	LastFired time.Time
+}
+
+// Alert is a fired alert rule, passed to the notifiers
+type Alert struct {
+	Rule     *AlertRule
+	Subject  string
+	Value    float64
+	Previous float64
+	Date     time.Time
+}
+
+func (r *AlertRule) GenerateUUID() {
+	if r.UUID != uuid.Nil {
+		return
+	}
+
+	r.UUID = uuid.New()
+}
+
+func (r *AlertRule) Validate() error {
+	switch r.Condition {
+	case AlertConditionDrop, AlertConditionAbove, AlertConditionBelow:
+	default:
+		return fmt.Errorf("%w: '%s'", ErrUnknownAlertCondition, r.Condition)
+	}
+
+	if r.ISIN == "" && r.Nomination == "" {
+		return ErrMissingNomination
+	}
+
+	if _, err := newNotifier(r.Notifier, r.Target, DefaultConfig()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Met returns whether the condition is met for the current and previous value
+func (r *AlertRule) Met(current, previous float64) bool {
+	switch r.Condition {
+	case AlertConditionAbove:
+		return current > r.Threshold
+	case AlertConditionBelow:
+		return current < r.Threshold
+	case AlertConditionDrop:
+		if previous <= 0 {
+			return false
+		}
+
+		return (previous-current)/previous*100 > r.Threshold
+	}
+
+	return false
+}
+
+func (r *AlertRule) Subject() string {
+	if r.ISIN == "" {
+		return "portfolio total (" + r.Nomination + ")"
+	}
+
+	return r.ISIN
+}
+
+func (r *AlertRule) String() string {
+	unit := ""
+	if r.Condition == AlertConditionDrop {
+		unit = "%"
+	}
+
+	return fmt.Sprintf("%s: %s %s %.2f%s -> %s %s", r.UUID, r.Subject(), r.Condition, r.Threshold, unit, r.Notifier, r.Target)
+}
+
+func (a *Alert) Message() string {
+	switch a.Rule.Condition {
+	case AlertConditionDrop:
+		return fmt.Sprintf(
+			"%s dropped %.2f%% from %.2f to %.2f on %s",
+			a.Subject, (a.Previous-a.Value)/a.Previous*100, a.Previous, a.Value, timeToDate(&a.Date),
+		)
+	default:
+		return fmt.Sprintf(
+			"%s is %s %.2f: %.2f on %s",
+			a.Subject, a.Rule.Condition, a.Rule.Threshold, a.Value, timeToDate(&a.Date),
+		)
+	}
+}
+
+func (db *DB) CreateAlertRule(r *AlertRule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	r.GenerateUUID()
+
+	return db.DB().Save(r)
+}
+
+func (db *DB) GetAllAlertRules() ([]AlertRule, error) {
+	var rules []AlertRule
+
+	err := db.DB().All(&rules)
+
+	return rules, err
+}
+
+func (db *DB) DeleteAlertRule(id string) error {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	var r AlertRule
+
+	if err := db.DB().One("UUID", u, &r); err != nil {
+		return err
+	}
+
+	return db.DB().DeleteStruct(&r)
+}
+
+func (db *DB) getAlertRulesFor(isin string) ([]AlertRule, error) {
+	var rules []AlertRule
+
+	err := db.DB().Select(
+		q.Or(q.Eq("ISIN", isin), q.Eq("ISIN", "")),
+	).Find(&rules)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, nil
+	}
+
+	return rules, err
+}
+
+// EvaluateAlerts checks the rules for the ISIN and the portfolio totals, and
+// queues an alert for the rules that started to meet their condition; they
+// are sent by SendAlerts
+func (db *DB) EvaluateAlerts(isin *ISIN) error {
+	db.alertsLock.Lock()
+	defer db.alertsLock.Unlock()
+
+	rules, err := db.getAlertRulesFor(isin.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range rules {
+		r := &rules[i]
+
+		var (
+			current, previous float64
+			subject           string
+		)
+
+		if r.ISIN == "" {
+			current, previous, err = db.portfolioAlertValues(r.Nomination)
+			subject = r.Subject()
+		} else {
+			current, previous, err = db.isinAlertValues(isin)
+			subject = fmt.Sprintf("%s (%s)", isin.ID, isin.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		met := r.Met(current, previous)
+		if met == r.Active {
+			continue
+		}
+
+		r.Active = met
+
+		if met {
+			r.LastFired = time.Now()
+
+			db.alerts = append(db.alerts, &Alert{
+				Rule:     r,
+				Subject:  subject,
+				Value:    current,
+				Previous: previous,
+				Date:     isin.UpdatedAt,
+			})
+		}
+
+		if err := db.DB().Save(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendAlerts sends the queued alerts; it is called after an import, so slow
+// notifiers do not hold up other imports
+func (db *DB) SendAlerts(ctx context.Context) {
+	db.alertsLock.Lock()
+	alerts := db.alerts
+	db.alerts = nil
+	db.alertsLock.Unlock()
+
+	for _, a := range alerts {
+		db.notify(ctx, a)
+	}
+}
+
+func (db *DB) notify(ctx context.Context, a *Alert) {
+	db.logger.Warnf("Alert: %s", a.Message())
+
+	n, err := newNotifier(a.Rule.Notifier, a.Rule.Target, db.config)
+	if err != nil {
+		db.logger.Errorf("Error notifying alert %s: %v", a.Rule.UUID, err)
+		return
+	}
+
+	if err := n.Notify(ctx, a); err != nil {
+		db.logger.Errorf("Error notifying alert %s: %v", a.Rule.UUID, err)
+	}
+}
+
+// isinAlertValues returns the last value per share and the one before that
+func (db *DB) isinAlertValues(isin *ISIN) (float64, float64, error) {
+	previous, err := db.GetValuationBefore(isin.ID, isin.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return isin.ValuePerShare, 0, nil
+		}
+
+		return 0, 0, err
+	}
+
+	return isin.ValuePerShare, db.ValueOf(isin, previous), nil
+}
+
+// portfolioAlertValues returns the current portfolio total in the nomination,
+// and the total valued at the valuations before the last ones
+func (db *DB) portfolioAlertValues(nomination string) (float64, float64, error) {
+	isins, err := db.GetAllISIN()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var current, previous float64
+
+	for k, i := range isins {
+		if i.Nomination != nomination {
+			continue
+		}
+
+		current += i.OwnedValue()
+
+		v, err := db.GetValuationBefore(i.ID, i.UpdatedAt)
+		if err != nil {
+			if !errors.Is(err, storm.ErrNotFound) {
+				return 0, 0, err
+			}
+
+			previous += i.OwnedValue()
+
+			continue
+		}
+
+		previous += i.ValueAt(db.ValueOf(&isins[k], v), i.Shares, v.Date)
+	}
+
+	return current, previous, nil
+}
@@ -47,7 +47,7 @@ func (a *App) ShowStateSince(tableFormat string, date time.Time) error {
 			continue
 		}
 
-		ownedValue := valuation.Value() * shares
+		ownedValue := isin.ValueAt(a.DB().ValueOf(&isin, valuation), shares, date)
 		totals1[isin.Nomination] += ownedValue
 		totals2[isin.Nomination] += isin.OwnedValue()
 		diff := isin.OwnedValue() - ownedValue
@@ -57,6 +57,27 @@ func (a *App) ShowStateSince(tableFormat string, date time.Time) error {
 		))
 	}
 
+	now := a.DB().Now()
+
+	cash, err := a.cashBalances(date)
+	if err != nil {
+		return err
+	}
+
+	current, err := a.cashBalances(now)
+	if err != nil {
+		return err
+	}
+
+	for k, c := range cash {
+		totals1[c.Account.Currency] += c.Balance
+		totals2[c.Account.Currency] += current[k].Balance
+
+		entries = append(entries, a.buildSinceTableEntry(
+			cashLabel, c.Account.Name, &now, c.Account.Currency, c.Balance, current[k].Balance, current[k].Balance-c.Balance,
+		))
+	}
+
 	a.showSinceTable(tableFormat, entries, totals1, totals2)
 
 	return nil
@@ -92,15 +113,23 @@ func (a *App) ShowStateAt(tableFormat string, date time.Time) error {
 			continue
 		}
 
-		ownedValue := valuation.Value() * shares
+		value := a.DB().ValueOf(&isin, valuation)
+		ownedValue := isin.ValueAt(value, shares, date)
 		totals[isin.Nomination] += ownedValue
 
-		entries = append(entries, a.buildSingleTableEntry(
-			isin.ID, isin.Name, &valuation.Date, isin.Nomination, valuation.Value(), shares, ownedValue,
-		))
+		entries = append(entries, a.bondPriceEntry(&isin, a.buildSingleTableEntry(
+			isin.ID, isin.Name, &valuation.Date, isin.Nomination, value, shares, ownedValue,
+		), value))
 	}
 
-	a.showSingleTable(tableFormat, entries, totals)
+	cash, err := a.cashEntries(date, totals)
+	if err != nil {
+		return err
+	}
+
+	entries = append(entries, cash...)
+
+	a.showSingleTable(tableFormat, singeStateHeaders, entries, totals)
 
 	return nil
 }
@@ -115,23 +144,122 @@ func (a *App) ShowCurrentState(tableFormat string) error {
 		return isins[i].ID < isins[j].ID
 	})
 
-	var entries [][]string
+	var (
+		entries [][]string
+		bonds   bool
+	)
+
+	for k := range isins {
+		bonds = bonds || isins[k].Bond != nil
+
+		if err := a.DB().currentValuePerShare(&isins[k]); err != nil {
+			return err
+		}
+	}
 
 	totals := map[string]float64{}
 
 	for _, isin := range isins {
 		totals[isin.Nomination] += isin.OwnedValue()
 
-		entries = append(entries, a.buildSingleTableEntry(
+		entry := a.buildSingleTableEntry(
 			isin.ID, isin.Name, &isin.UpdatedAt, isin.Nomination, isin.ValuePerShare, isin.Shares, isin.OwnedValue(),
-		))
+		)
+
+		if bonds {
+			entry = append(a.bondPriceEntry(&isin, entry, isin.ValuePerShare), a.ytmEntry(&isin))
+		}
+
+		entries = append(entries, entry)
+	}
+
+	cash, err := a.cashEntries(a.DB().Now(), totals)
+	if err != nil {
+		return err
+	}
+
+	entries = append(entries, cash...)
+
+	headers := singeStateHeaders
+	if bonds {
+		headers = append(headers[:len(headers):len(headers)], "YTM")
 	}
 
-	a.showSingleTable(tableFormat, entries, totals)
+	a.showSingleTable(tableFormat, headers, entries, totals)
 
 	return nil
 }
 
+// bondPriceEntry shows the price of a bond as a percentage of its nominal
+func (a *App) bondPriceEntry(isin *ISIN, entry []string, price float64) []string {
+	if isin.Bond != nil {
+		entry[4] = fmt.Sprintf("%.3f%%", price)
+	}
+
+	return entry
+}
+
+// ytmEntry returns the yield to maturity of a bond at its last price
+func (a *App) ytmEntry(isin *ISIN) string {
+	if isin.Bond == nil {
+		return ""
+	}
+
+	ytm, ok := isin.Bond.YieldToMaturity(isin.ValuePerShare, isin.UpdatedAt)
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%.2f%%", ytm)
+}
+
+type cashBalance struct {
+	Account CashAccount
+	Balance float64
+}
+
+// cashBalances returns the balance of every cash account at the date
+func (a *App) cashBalances(date time.Time) ([]cashBalance, error) {
+	accounts, err := a.DB().GetAllCashAccounts()
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	result := make([]cashBalance, 0, len(accounts))
+
+	for k := range accounts {
+		b, err := a.DB().CashBalance(&accounts[k], date)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, cashBalance{accounts[k], b})
+	}
+
+	return result, nil
+}
+
+// cashEntries returns the table rows of the cash accounts at the date, and
+// adds their balances to the totals
+func (a *App) cashEntries(date time.Time, totals map[string]float64) ([][]string, error) {
+	balances, err := a.cashBalances(date)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries [][]string
+
+	for _, c := range balances {
+		totals[c.Account.Currency] += c.Balance
+
+		entries = append(entries, []string{
+			cashLabel, c.Account.Name, c.Account.Currency, timeToDate(&date), "", "", a.localize(c.Account.Currency, c.Balance),
+		})
+	}
+
+	return entries, nil
+}
+
 func (a *App) buildSinceTableEntry(isinID string, isinName string, date *time.Time, nomination string, val1, val2, diff float64) []string {
 	locVal1, err := a.currency.Localize(nomination, val1)
 	if err != nil {
@@ -165,16 +293,18 @@ func (a *App) buildSingleTableEntry(isinID string, isinName string, date *time.T
 	}
 
 	return []string{
-		isinID, isinName, nomination, timeToDate(date), vps, fmt.Sprintf("%.2f", shares), locOwnedValue,
+		isinID, isinName, nomination, timeToDate(date), vps, formatShares(isinID, shares, 2), locOwnedValue,
 	}
 }
 
-func (a *App) showSingleTable(tableFormat string, entries [][]string, totals map[string]float64) {
+func (a *App) showSingleTable(tableFormat string, headers []string, entries [][]string, totals map[string]float64) {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader(singeStateHeaders)
+	table.SetHeader(headers)
 	configureRenderer(table, tableFormat)
 
-	table.AppendBulk(entries)
+	for _, e := range entries {
+		table.Append(append(e, make([]string, len(headers)-len(e))...))
+	}
 
 	for nom, value := range totals {
 		tv, err := a.currency.Localize(nom, value)
@@ -182,7 +312,8 @@ func (a *App) showSingleTable(tableFormat string, entries [][]string, totals map
 			a.Logger().Error(err)
 		}
 
-		table.Append([]string{"Total", "", nom, "", "", "", tv})
+		row := []string{"Total", "", nom, "", "", "", tv}
+		table.Append(append(row, make([]string, len(headers)-len(row))...))
 	}
 
 	table.Render()
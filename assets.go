@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+)
+
+const (
+	AssetKindAsset     = "asset"
+	AssetKindLiability = "liability"
+
+	assetPrefix = "ASSET:"
+)
+
+var (
+	ErrAssetKind     = errors.New("unknown asset kind")
+	ErrAssetExists   = errors.New("asset already exists")
+	ErrAssetCurrency = errors.New("an asset needs a currency")
+	ErrAssetValue    = errors.New("value must be zero or positive")
+)
+
+// Asset is something owned or owed without a market price, like real estate
+// or a mortgage; its values are stored as manual valuations under a pseudo
+// ISIN. Liabilities are valued at the amount still owed
+type Asset struct {
+	Name     string `storm:"id"`
+	Kind     string
+	Category string
+	Currency string
+	Created  time.Time
+}
+
+// NetWorth is the value of everything owned and owed at a date, in one
+// currency
+type NetWorth struct {
+	Date        time.Time
+	Securities  float64
+	Cash        float64
+	Assets      float64
+	Liabilities float64
+}
+
+func (n *NetWorth) Net() float64 {
+	return n.Securities + n.Cash + n.Assets - n.Liabilities
+}
+
+// BalanceItem is a single line of the balance sheet, valued in its own
+// currency and in the base currency
+type BalanceItem struct {
+	Kind     string
+	Name     string
+	Currency string
+	Date     time.Time
+	Value    float64
+	Base     float64
+}
+
+// assetID is the pseudo ISIN under which the values of the asset are stored
+func assetID(name string) string {
+	return assetPrefix + name
+}
+
+// Sign returns -1 for liabilities and 1 for assets
+func (a *Asset) Sign() float64 {
+	if a.Kind == AssetKindLiability {
+		return -1
+	}
+
+	return 1
+}
+
+func (a *Asset) Validate() error {
+	switch a.Kind {
+	case AssetKindAsset, AssetKindLiability:
+	default:
+		return fmt.Errorf("%w: '%s' (expected %s or %s)", ErrAssetKind, a.Kind, AssetKindAsset, AssetKindLiability)
+	}
+
+	if a.Currency == "" {
+		return ErrAssetCurrency
+	}
+
+	return nil
+}
+
+func (db *DB) CreateAsset(a *Asset) error {
+	a.Currency = strings.ToUpper(a.Currency)
+
+	if err := a.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := db.GetAsset(a.Name); err == nil {
+		return fmt.Errorf("%w: '%s'", ErrAssetExists, a.Name)
+	} else if !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	a.Created = db.Now()
+
+	return db.DB().Save(a)
+}
+
+func (db *DB) GetAsset(name string) (*Asset, error) {
+	var a Asset
+
+	if err := db.DB().One("Name", name, &a); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func (db *DB) GetAllAssets() ([]Asset, error) {
+	var assets []Asset
+
+	if err := db.DB().All(&assets); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Name < assets[j].Name
+	})
+
+	return assets, nil
+}
+
+// DeleteAsset deletes the asset and its values
+func (db *DB) DeleteAsset(name string) error {
+	a, err := db.GetAsset(name)
+	if err != nil {
+		return err
+	}
+
+	if err := db.DB().Select(q.Eq("ISIN", assetID(name))).Delete(&Valuation{}); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	return db.DB().DeleteStruct(a)
+}
+
+// SetAssetValue stores the value of the asset, or the amount still owed on
+// the liability, at the date
+func (db *DB) SetAssetValue(name string, d time.Time, value float64) error {
+	if _, err := db.GetAsset(name); err != nil {
+		return fmt.Errorf("%w: asset '%s'", err, name)
+	}
+
+	if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w: %g", ErrAssetValue, value)
+	}
+
+	v := Valuation{
+		ISIN:   assetID(name),
+		Date:   d,
+		Open:   value,
+		High:   value,
+		Low:    value,
+		Close:  value,
+		Source: DataSourceManual,
+	}
+
+	v.UpdateID()
+
+	return db.DB().Save(&v)
+}
+
+// GetAssetValues returns all values of the asset, oldest first
+func (db *DB) GetAssetValues(name string) ([]Valuation, error) {
+	var values []Valuation
+
+	err := db.DB().Select(q.Eq("ISIN", assetID(name))).OrderBy("Date").Find(&values)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, nil
+	}
+
+	return values, err
+}
+
+// AssetValueAt returns the last known value of the asset at the date, and the
+// date of that value; assets without a value yet are worth nothing
+func (db *DB) AssetValueAt(name string, d time.Time) (float64, time.Time, error) {
+	v, err := db.GetValuationAt(assetID(name), d)
+	if errors.Is(err, storm.ErrNotFound) {
+		return 0, time.Time{}, nil
+	}
+
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return v.Close, v.Date, nil
+}
+
+// toBase converts the amount from the currency to the base currency; amounts
+// without a currency are taken as is
+func (db *DB) toBase(amount float64, currency string, d time.Time) (float64, error) {
+	if currency == "" || amount == 0 {
+		return amount, nil
+	}
+
+	v, err := db.Convert(amount, currency, db.config.BaseCurrency, d)
+	if err != nil {
+		return 0, fmt.Errorf("%w (run 'fx update' or 'fx set')", err)
+	}
+
+	return v, nil
+}
+
+// BalanceSheet returns the securities, cash accounts, assets and liabilities
+// at the date; liabilities have a negative value
+func (db *DB) BalanceSheet(d time.Time) ([]BalanceItem, error) {
+	if db.config.BaseCurrency == "" {
+		return nil, ErrNoBaseCurrency
+	}
+
+	var items []BalanceItem
+
+	isins, err := db.GetAllISIN()
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range isins {
+		i := &isins[k]
+
+		shares, err := db.GetSharesAt(i.ID, d)
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return nil, err
+		}
+
+		if shares == 0 {
+			continue
+		}
+
+		v, err := db.GetValuationAt(i.ID, d)
+		if errors.Is(err, storm.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, BalanceItem{Kind: "security", Name: i.ID, Currency: i.Nomination, Date: v.Date, Value: i.ValueAt(db.ValueOf(i, v), shares, d)})
+	}
+
+	accounts, err := db.GetAllCashAccounts()
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	for k := range accounts {
+		c := &accounts[k]
+
+		b, err := db.CashBalance(c, d)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, BalanceItem{Kind: cashLabel, Name: c.Name, Currency: c.Currency, Date: d, Value: b})
+	}
+
+	assets, err := db.GetAllAssets()
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	for k := range assets {
+		a := &assets[k]
+
+		v, date, err := db.AssetValueAt(a.Name, d)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, BalanceItem{Kind: a.Kind, Name: a.Name, Currency: a.Currency, Date: date, Value: v * a.Sign()})
+	}
+
+	for k := range items {
+		if items[k].Base, err = db.toBase(items[k].Value, items[k].Currency, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// NetWorthAt returns the totals of the balance sheet at the date, in the base
+// currency
+func (db *DB) NetWorthAt(d time.Time) (*NetWorth, error) {
+	items, err := db.BalanceSheet(d)
+	if err != nil {
+		return nil, err
+	}
+
+	n := NetWorth{Date: d}
+
+	for _, i := range items {
+		switch i.Kind {
+		case cashLabel:
+			n.Cash += i.Base
+		case AssetKindAsset:
+			n.Assets += i.Base
+		case AssetKindLiability:
+			n.Liabilities -= i.Base
+		default:
+			n.Securities += i.Base
+		}
+	}
+
+	return &n, nil
+}
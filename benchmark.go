@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	ErrNoBenchmark        = errors.New("no benchmark marked, use --benchmark or 'set-benchmark'")
+	ErrAmbiguousBenchmark = errors.New("several benchmarks marked, choose one with --benchmark")
+	ErrNoBenchmarkPrice   = errors.New("no price of the benchmark (run 'backfill')")
+	ErrNoTransactions     = errors.New("no transactions to compare")
+)
+
+// BenchmarkPoint compares the portfolio with the same cash flows invested in
+// the benchmark at a date; all amounts are in the base currency. Invested is
+// the money put in minus the money taken out. Both sides are price only:
+// dividends are left out, as the prices of the benchmark do not include its
+// own
+type BenchmarkPoint struct {
+	Date      time.Time
+	Invested  float64
+	Portfolio float64
+	Benchmark float64
+}
+
+// Return returns the gain of the portfolio relative to the money invested
+func (p *BenchmarkPoint) Return() float64 {
+	return relativeGain(p.Portfolio, p.Invested)
+}
+
+// BenchmarkReturn returns the gain of the benchmark relative to the money
+// invested
+func (p *BenchmarkPoint) BenchmarkReturn() float64 {
+	return relativeGain(p.Benchmark, p.Invested)
+}
+
+func relativeGain(value, invested float64) float64 {
+	if invested == 0 {
+		return 0
+	}
+
+	return (value - invested) / invested * 100
+}
+
+// SetBenchmark marks the ISIN as a benchmark, or unmarks it
+func (db *DB) SetBenchmark(isinID string, benchmark bool) error {
+	isin, err := db.GetISIN(isinID)
+	if err != nil {
+		return err
+	}
+
+	isin.Benchmark = benchmark
+
+	return db.DB().Save(isin)
+}
+
+// DefaultBenchmark returns the only ISIN marked as benchmark
+func (db *DB) DefaultBenchmark() (*ISIN, error) {
+	var isins []ISIN
+
+	err := db.DB().Select(q.Eq("Benchmark", true)).Find(&isins)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	switch len(isins) {
+	case 0:
+		return nil, ErrNoBenchmark
+	case 1:
+		return &isins[0], nil
+	default:
+		return nil, ErrAmbiguousBenchmark
+	}
+}
+
+// benchmarkPrice returns the price of the benchmark at the date
+func (db *DB) benchmarkPrice(bench *ISIN, d time.Time) (float64, error) {
+	v, err := db.GetValuationAt(bench.ID, d)
+	if errors.Is(err, storm.ErrNotFound) {
+		return 0, fmt.Errorf("%w: '%s' at %s", ErrNoBenchmarkPrice, bench.ID, timeToDate(&d))
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return db.ValueOf(bench, v), nil
+}
+
+// CompareBenchmark simulates investing the cash flows of the trades into the
+// benchmark at the prices of the same dates, and returns the comparison at
+// every date; the dates have to be in order. The benchmark is part of the
+// portfolio only when it was traded
+func (db *DB) CompareBenchmark(bench *ISIN, dates []time.Time) ([]BenchmarkPoint, error) {
+	if db.config.BaseCurrency == "" {
+		return nil, ErrNoBaseCurrency
+	}
+
+	isins, err := db.GetAllISIN()
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []Transaction
+
+	if err := db.DB().All(&transactions); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	held := false
+
+	for _, t := range transactions {
+		if t.ISIN == bench.ID {
+			held = true
+
+			break
+		}
+	}
+
+	portfolio := map[string]*ISIN{}
+
+	for k := range isins {
+		if held || isins[k].ID != bench.ID {
+			portfolio[isins[k].ID] = &isins[k]
+		}
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Date.Before(transactions[j].Date)
+	})
+
+	var (
+		result   []BenchmarkPoint
+		invested float64
+		units    float64
+		next     int
+		warned   bool
+	)
+
+	for _, d := range dates {
+		for ; next < len(transactions) && !transactions[next].Date.After(d); next++ {
+			t := &transactions[next]
+
+			i, ok := portfolio[t.ISIN]
+			if !ok || t.IsDividend() {
+				continue
+			}
+
+			// Money put in for a buy, or taken out (negative) for a sale
+			flow, err := db.toBase(t.TotalValue+t.Fee, i.Nomination, t.Date)
+			if err != nil {
+				return nil, err
+			}
+
+			invested += flow
+
+			price, err := db.benchmarkPrice(bench, t.Date)
+			if err != nil {
+				return nil, err
+			}
+
+			amount := flow
+			if bench.Nomination != "" {
+				if amount, err = db.Convert(flow, db.config.BaseCurrency, bench.Nomination, t.Date); err != nil {
+					return nil, fmt.Errorf("%w (run 'fx update' or 'fx set')", err)
+				}
+			}
+
+			units += amount / price
+
+			if units < 0 && !warned {
+				db.logger.Warnf("Sale at %s takes out more than the benchmark is worth", timeToDate(&t.Date))
+
+				warned = true
+			}
+		}
+
+		p := BenchmarkPoint{Date: d, Invested: invested}
+
+		for _, i := range portfolio {
+			shares, err := db.GetSharesAt(i.ID, d)
+			if err != nil && !errors.Is(err, storm.ErrNotFound) {
+				return nil, err
+			}
+
+			if shares == 0 {
+				continue
+			}
+
+			v, err := db.GetValuationAt(i.ID, d)
+			if errors.Is(err, storm.ErrNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := db.toBase(i.ValueAt(db.ValueOf(i, v), shares, d), i.Nomination, d)
+			if err != nil {
+				return nil, err
+			}
+
+			p.Portfolio += value
+		}
+
+		if units != 0 {
+			price, err := db.benchmarkPrice(bench, d)
+			if err != nil {
+				return nil, err
+			}
+
+			if p.Benchmark, err = db.toBase(units*price, bench.Nomination, d); err != nil {
+				return nil, err
+			}
+		}
+
+		result = append(result, p)
+	}
+
+	return result, nil
+}
+
+// ShowComparison prints the portfolio against the benchmark at every
+// interval since the first transaction, both without dividends
+func (a *App) ShowComparison(tableFormat string, benchID string, interval string) error {
+	var (
+		bench *ISIN
+		err   error
+	)
+
+	if benchID == "" {
+		bench, err = a.DB().DefaultBenchmark()
+	} else {
+		bench, err = a.DB().GetISIN(benchID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var first Transaction
+
+	if err := a.DB().DB().Select().OrderBy("Date").Limit(1).First(&first); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return ErrNoTransactions
+		}
+
+		return err
+	}
+
+	now := a.DB().Now()
+
+	var dates []time.Time
+
+	for step, d := 1, first.Date; d.Before(now); step++ {
+		dates = append(dates, d)
+
+		if d, err = intervalDate(first.Date, interval, step); err != nil {
+			return err
+		}
+	}
+
+	dates = append(dates, now)
+
+	points, err := a.DB().CompareBenchmark(bench, dates)
+	if err != nil {
+		return err
+	}
+
+	base := a.config.BaseCurrency
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Date", "Invested", "Portfolio (price only)", bench.ID + " (price only)", "Difference", "Return", "Benchmark return", "Excess"})
+	configureRenderer(table, tableFormat)
+
+	for _, p := range points {
+		table.Append([]string{
+			timeToDate(&p.Date), a.localize(base, p.Invested), a.localize(base, p.Portfolio), a.localize(base, p.Benchmark),
+			a.localize(base, p.Portfolio-p.Benchmark), fmt.Sprintf("%.2f%%", p.Return()), fmt.Sprintf("%.2f%%", p.BenchmarkReturn()),
+			fmt.Sprintf("%+.2f%%", p.Return()-p.BenchmarkReturn()),
+		})
+	}
+
+	table.Render()
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	ErrBondFrequency = errors.New("coupon frequency must be 1, 2, 4 or 12 payments per year")
+	ErrBondNominal   = errors.New("nominal must be positive")
+	ErrBondMaturity  = errors.New("a bond needs a maturity date")
+)
+
+// BondTerms describe an individual bond: the shares of the ISIN are the
+// number of bonds with the nominal value, its price is a percentage of the
+// nominal, and the coupon (in percent of the nominal per year) is paid in
+// equal parts Frequency times per year, counting back from the maturity
+type BondTerms struct {
+	Nominal   float64
+	Coupon    float64
+	Frequency int
+	Maturity  time.Time
+}
+
+// CashFlow is an expected payment of a bond: a coupon, or the nominal at
+// maturity
+type CashFlow struct {
+	Date   time.Time
+	ISIN   string
+	Type   string
+	Amount float64
+}
+
+func (b *BondTerms) Validate() error {
+	switch b.Frequency {
+	case 1, 2, 4, 12:
+	default:
+		return fmt.Errorf("%w: %d", ErrBondFrequency, b.Frequency)
+	}
+
+	if b.Nominal <= 0 {
+		return fmt.Errorf("%w: %g", ErrBondNominal, b.Nominal)
+	}
+
+	if b.Maturity.IsZero() {
+		return ErrBondMaturity
+	}
+
+	return nil
+}
+
+// couponAmount returns the coupon of one bond for a single period
+func (b *BondTerms) couponAmount() float64 {
+	return b.Nominal * b.Coupon / 100 / float64(b.Frequency)
+}
+
+// couponDate returns the coupon date n periods before the maturity; the day
+// is clamped to the end of shorter months, so a maturity on August 31 pays
+// on February 28 and not on March 3
+func (b *BondTerms) couponDate(n int) time.Time {
+	return addMonths(b.Maturity, -n*12/b.Frequency)
+}
+
+// couponPeriod returns the coupon dates before and at or after the date
+func (b *BondTerms) couponPeriod(d time.Time) (time.Time, time.Time) {
+	next := b.Maturity
+
+	for n := 1; ; n++ {
+		prev := b.couponDate(n)
+		if !prev.After(d) {
+			return prev, next
+		}
+
+		next = prev
+	}
+}
+
+// Accrued returns the interest accrued on one bond since the last coupon,
+// pro rata of the days in the coupon period; nothing accrues after maturity
+func (b *BondTerms) Accrued(d time.Time) float64 {
+	if !d.Before(b.Maturity) {
+		return 0
+	}
+
+	prev, next := b.couponPeriod(d)
+
+	return b.couponAmount() * d.Sub(prev).Hours() / next.Sub(prev).Hours()
+}
+
+// couponDates returns the coupon dates after the date, up to the maturity
+func (b *BondTerms) couponDates(d time.Time) []time.Time {
+	var dates []time.Time
+
+	for n := 0; ; n++ {
+		c := b.couponDate(n)
+		if !c.After(d) {
+			break
+		}
+
+		dates = append([]time.Time{c}, dates...)
+	}
+
+	return dates
+}
+
+// YieldToMaturity returns the annual yield (in percent, compounded at the
+// coupon frequency) that discounts the remaining coupons and the nominal to
+// the price (in percent of the nominal) plus the accrued interest at the date
+func (b *BondTerms) YieldToMaturity(price float64, d time.Time) (float64, bool) {
+	dates := b.couponDates(d)
+	if len(dates) == 0 || price <= 0 {
+		return 0, false
+	}
+
+	f := float64(b.Frequency)
+	coupon := b.Coupon / f
+	dirty := price + b.Accrued(d)/b.Nominal*100
+
+	prev, next := b.couponPeriod(d)
+	first := next.Sub(d).Hours() / next.Sub(prev).Hours()
+
+	presentValue := func(y float64) float64 {
+		var pv float64
+
+		for n := range dates {
+			cf := coupon
+			if n == len(dates)-1 {
+				cf += 100
+			}
+
+			pv += cf / math.Pow(1+y/f, first+float64(n))
+		}
+
+		return pv
+	}
+
+	// The present value decreases with the yield: bisect between -99% and
+	// 1000%
+	low, high := -0.99, 10.0
+
+	if presentValue(low) < dirty || presentValue(high) > dirty {
+		return 0, false
+	}
+
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+
+		if presentValue(mid) > dirty {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return (low + high) / 2 * 100, true
+}
+
+// ValueAt returns the value of the shares at the price; bonds are valued at
+// their price in percent of the nominal plus the interest accrued at the date
+func (i *ISIN) ValueAt(price, shares float64, d time.Time) float64 {
+	if i.Bond == nil {
+		return price * shares
+	}
+
+	return shares * (i.Bond.Nominal*price/100 + i.Bond.Accrued(d))
+}
+
+// SetBondTerms makes the ISIN an individual bond, or a regular security
+// again when the terms are nil
+func (db *DB) SetBondTerms(isinID string, terms *BondTerms) error {
+	isin, err := db.GetISIN(isinID)
+	if err != nil {
+		return err
+	}
+
+	if terms != nil {
+		if err := terms.Validate(); err != nil {
+			return err
+		}
+	}
+
+	isin.Bond = terms
+
+	return db.DB().Save(isin)
+}
+
+// BondCashFlows returns the coupons and redemptions of the bonds held, after
+// the date and up to the end date
+func (db *DB) BondCashFlows(from, until time.Time) ([]CashFlow, error) {
+	isins, err := db.GetAllISIN()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []CashFlow
+
+	for k := range isins {
+		i := &isins[k]
+
+		if i.Bond == nil || i.Shares == 0 {
+			continue
+		}
+
+		for _, d := range i.Bond.couponDates(from) {
+			if d.After(until) {
+				break
+			}
+
+			if i.Bond.Coupon != 0 {
+				result = append(result, CashFlow{Date: d, ISIN: i.ID, Type: "coupon", Amount: i.Shares * i.Bond.couponAmount()})
+			}
+
+			if d.Equal(i.Bond.Maturity) {
+				result = append(result, CashFlow{Date: d, ISIN: i.ID, Type: "redemption", Amount: i.Shares * i.Bond.Nominal})
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+
+	return result, nil
+}
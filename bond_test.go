@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCouponDate(t *testing.T) {
+	tests := []struct {
+		maturity  time.Time
+		frequency int
+		n         int
+		want      time.Time
+	}{
+		{date(2030, 8, 31), 2, 0, date(2030, 8, 31)},
+		{date(2030, 8, 31), 2, 1, date(2030, 2, 28)},
+		{date(2030, 8, 31), 2, 2, date(2029, 8, 31)},
+		{date(2028, 8, 31), 2, 1, date(2028, 2, 29)},
+		{date(2030, 8, 31), 4, 1, date(2030, 5, 31)},
+		{date(2030, 8, 31), 4, 3, date(2029, 11, 30)},
+		{date(2030, 1, 31), 12, 1, date(2029, 12, 31)},
+		{date(2030, 1, 31), 12, 11, date(2029, 2, 28)},
+		{date(2030, 3, 15), 1, 5, date(2025, 3, 15)},
+	}
+
+	for _, tt := range tests {
+		b := BondTerms{Nominal: 1000, Coupon: 4, Frequency: tt.frequency, Maturity: tt.maturity}
+
+		if got := b.couponDate(tt.n); !got.Equal(tt.want) {
+			t.Errorf("coupon %d before %s at %d per year is %s, want %s", tt.n, timeToDate(&tt.maturity), tt.frequency, timeToDate(&got), timeToDate(&tt.want))
+		}
+	}
+}
+
+func TestAccrued(t *testing.T) {
+	tests := []struct {
+		frequency int
+		d         time.Time
+		want      float64
+	}{
+		{1, date(2025, 6, 15), 0},
+		{1, date(2025, 12, 15), 40 * 183 / 365.0},
+		{2, date(2025, 9, 15), 20 * 92 / 183.0},
+		{2, date(2025, 12, 14), 20 * 182 / 183.0},
+		{1, date(2030, 6, 15), 0},
+		{1, date(2031, 1, 1), 0},
+	}
+
+	for _, tt := range tests {
+		b := BondTerms{Nominal: 1000, Coupon: 4, Frequency: tt.frequency, Maturity: date(2030, 6, 15)}
+
+		if got := b.Accrued(tt.d); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("accrued at %s at %d per year is %g, want %g", timeToDate(&tt.d), tt.frequency, got, tt.want)
+		}
+	}
+}
+
+func TestYieldToMaturity(t *testing.T) {
+	tests := []struct {
+		name      string
+		coupon    float64
+		frequency int
+		price     float64
+		d         time.Time
+		want      float64
+		ok        bool
+	}{
+		{"at par", 4, 1, 100, date(2025, 6, 15), 4, true},
+		{"at par, semi-annual", 4, 2, 100, date(2025, 6, 15), 4, true},
+		{"below par", 4, 1, 95.670525, date(2025, 6, 15), 5, true},
+		{"zero coupon", 0, 1, 100 / 1.05 / 1.05, date(2028, 6, 15), 5, true},
+		{"no price", 4, 1, 0, date(2025, 6, 15), 0, false},
+		{"matured", 4, 1, 100, date(2030, 6, 15), 0, false},
+	}
+
+	for _, tt := range tests {
+		b := BondTerms{Nominal: 1000, Coupon: tt.coupon, Frequency: tt.frequency, Maturity: date(2030, 6, 15)}
+
+		got, ok := b.YieldToMaturity(tt.price, tt.d)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("%s: yield is %g (%v), want %g (%v)", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
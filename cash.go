@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/google/uuid"
+)
+
+const (
+	CashMovementDeposit    = "deposit"
+	CashMovementWithdrawal = "withdrawal"
+	CashMovementInterest   = "interest"
+	CashMovementTrade      = "trade"
+	CashMovementDividend   = "dividend"
+
+	cashLabel = "cash"
+)
+
+var (
+	ErrCashAccountExists = errors.New("cash account already exists")
+	ErrCashAccountInUse  = errors.New("cash account is used by transactions")
+	ErrCashCurrency      = errors.New("a cash account needs a currency")
+	ErrCashAmount        = errors.New("amount must be positive")
+)
+
+// CashAccount is a broker cash or savings account; its balance is the sum of
+// its movements and of the transactions paid from it
+type CashAccount struct {
+	Name     string `storm:"id"`
+	Currency string
+	Created  time.Time
+}
+
+// CashMovement is money put into or taken out of a cash account; the amount
+// is negative for withdrawals. Movements of transactions are not stored, but
+// derived from the transactions when needed
+type CashMovement struct {
+	UUID    uuid.UUID `storm:"id"`
+	Account string    `storm:"index"`
+	Date    time.Time `storm:"index"`
+	Type    string
+	Amount  float64
+	Note    string
+}
+
+func (m *CashMovement) GenerateUUID() {
+	if m.UUID != uuid.Nil {
+		return
+	}
+
+	m.UUID = uuid.New()
+}
+
+// CashAmount returns the money the transaction adds to its cash account: the
+// net dividend, or the value of a trade with the fee, negative for a buy
+func (t *Transaction) CashAmount() float64 {
+	if t.IsDividend() {
+		return t.Net()
+	}
+
+	return -(t.TotalValue + t.Fee)
+}
+
+func (db *DB) CreateCashAccount(name, currency string) error {
+	if currency == "" {
+		return ErrCashCurrency
+	}
+
+	if _, err := db.GetCashAccount(name); err == nil {
+		return fmt.Errorf("%w: '%s'", ErrCashAccountExists, name)
+	} else if !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	return db.DB().Save(&CashAccount{Name: name, Currency: strings.ToUpper(currency), Created: db.Now()})
+}
+
+func (db *DB) GetCashAccount(name string) (*CashAccount, error) {
+	var c CashAccount
+
+	if err := db.DB().One("Name", name, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (db *DB) GetAllCashAccounts() ([]CashAccount, error) {
+	var accounts []CashAccount
+
+	if err := db.DB().All(&accounts); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+
+	return accounts, nil
+}
+
+// DeleteCashAccount deletes the account and its movements; accounts still
+// used by transactions are kept
+func (db *DB) DeleteCashAccount(name string) error {
+	c, err := db.GetCashAccount(name)
+	if err != nil {
+		return err
+	}
+
+	n, err := db.DB().Select(q.Eq("Account", name)).Count(&Transaction{})
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return fmt.Errorf("%w: '%s' (%d transactions)", ErrCashAccountInUse, name, n)
+	}
+
+	if err := db.DB().Select(q.Eq("Account", name)).Delete(&CashMovement{}); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	return db.DB().DeleteStruct(c)
+}
+
+// AddCashMovement records a deposit, withdrawal or interest payment; the
+// amount is positive, withdrawals are stored as negative amounts
+func (db *DB) AddCashMovement(m *CashMovement) error {
+	if _, err := db.GetCashAccount(m.Account); err != nil {
+		return fmt.Errorf("%w: cash account '%s'", err, m.Account)
+	}
+
+	if m.Amount <= 0 {
+		return fmt.Errorf("%w: %g", ErrCashAmount, m.Amount)
+	}
+
+	if m.Type == CashMovementWithdrawal {
+		m.Amount = -m.Amount
+	}
+
+	m.GenerateUUID()
+
+	return db.DB().Save(m)
+}
+
+func (db *DB) DeleteCashMovement(id string) error {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	var m CashMovement
+
+	if err := db.DB().One("UUID", u, &m); err != nil {
+		return err
+	}
+
+	return db.DB().DeleteStruct(&m)
+}
+
+// CashMovements returns the movements of the account up to the date, with
+// the movements of its transactions in the currency of the account
+func (db *DB) CashMovements(account *CashAccount, until time.Time) ([]CashMovement, error) {
+	var movements []CashMovement
+
+	err := db.DB().Select(
+		q.Eq("Account", account.Name),
+		q.Lte("Date", until),
+	).Find(&movements)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	var transactions []Transaction
+
+	err = db.DB().Select(
+		q.Eq("Account", account.Name),
+		q.Lte("Date", until),
+	).Find(&transactions)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	for k := range transactions {
+		t := &transactions[k]
+
+		amount, err := db.transactionCash(t, account.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		kind := CashMovementTrade
+		if t.IsDividend() {
+			kind = CashMovementDividend
+		}
+
+		movements = append(movements, CashMovement{
+			UUID: t.UUID, Account: account.Name, Date: t.Date, Type: kind, Amount: amount, Note: t.ISIN,
+		})
+	}
+
+	sort.SliceStable(movements, func(i, j int) bool {
+		return movements[i].Date.Before(movements[j].Date)
+	})
+
+	return movements, nil
+}
+
+// transactionCash returns the cash amount of the transaction, converted from
+// the nomination of its ISIN to the currency
+func (db *DB) transactionCash(t *Transaction, currency string) (float64, error) {
+	isin, err := db.GetISIN(t.ISIN)
+	if err != nil {
+		return 0, err
+	}
+
+	if isin.Nomination == "" {
+		return t.CashAmount(), nil
+	}
+
+	amount, err := db.Convert(t.CashAmount(), isin.Nomination, currency, t.Date)
+	if err != nil {
+		return 0, fmt.Errorf("%w (run 'fx update' or 'fx set')", err)
+	}
+
+	return amount, nil
+}
+
+// CashBalance returns the balance of the account at the date
+func (db *DB) CashBalance(account *CashAccount, at time.Time) (float64, error) {
+	movements, err := db.CashMovements(account, at)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance float64
+
+	for _, m := range movements {
+		balance += m.Amount
+	}
+
+	return balance, nil
+}
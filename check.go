@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	CheckStale       = "stale"
+	CheckQuarantined = "quarantined"
+	CheckConflict    = "conflict"
+	CheckSign        = "sign"
+)
+
+var ErrCheckFailed = errors.New("suspicious prices found")
+
+// CheckIssue is something about the prices of an ISIN that needs attention
+type CheckIssue struct {
+	ISIN    string
+	Kind    string
+	Date    time.Time
+	Details string
+}
+
+// Check lists the ISINs that were not updated for more than the configured
+// number of business days, the quarantined valuations, the conflicts, and the
+// trades stored before their value had to have the sign of their shares
+func (db *DB) Check() ([]CheckIssue, error) {
+	var issues []CheckIssue
+
+	all, err := db.GetAllISIN()
+	if err != nil {
+		return nil, err
+	}
+
+	now := db.Now()
+
+	for _, i := range all {
+		if i.UpdatedAt.IsZero() {
+			issues = append(issues, CheckIssue{ISIN: i.ID, Kind: CheckStale, Details: "never updated"})
+			continue
+		}
+
+		if days := businessDaysBetween(i.UpdatedAt, now); days > db.config.StaleDays {
+			issues = append(issues, CheckIssue{
+				ISIN: i.ID, Kind: CheckStale, Date: i.UpdatedAt,
+				Details: fmt.Sprintf("last price %d business days ago", days),
+			})
+		}
+	}
+
+	quarantine, err := db.GetQuarantine()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, qv := range quarantine {
+		issues = append(issues, CheckIssue{
+			ISIN: qv.ISIN, Kind: CheckQuarantined, Date: qv.Valuation.Date,
+			Details: fmt.Sprintf("%s (%s, id %s)", qv.Reason, qv.Valuation.Source, qv.ID),
+		})
+	}
+
+	conflicts, err := db.GetAllConflicts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range conflicts {
+		issues = append(issues, CheckIssue{
+			ISIN: c.ISIN, Kind: CheckConflict, Date: c.Date,
+			Details: fmt.Sprintf("%s %.4f vs %s %.4f (%.2f%%)", c.Source, c.Value, c.OtherSource, c.OtherValue, c.DiffPercent),
+		})
+	}
+
+	var transactions []Transaction
+
+	if err := db.DB().All(&transactions); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	for _, t := range transactions {
+		if err := t.Validate(); errors.Is(err, ErrTransactionSign) {
+			issues = append(issues, CheckIssue{
+				ISIN: t.ISIN, Kind: CheckSign, Date: t.Date,
+				Details: fmt.Sprintf("%v (transaction %s)", err, t.UUID),
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].ISIN != issues[j].ISIN {
+			return issues[i].ISIN < issues[j].ISIN
+		}
+
+		return issues[i].Date.Before(issues[j].Date)
+	})
+
+	return issues, nil
+}
+
+// ShowCheck prints the issues, and fails when there are any
+func (a *App) ShowCheck(tableFormat string) error {
+	issues, err := a.DB().Check()
+	if err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ISIN", "Issue", "Date", "Details"})
+	configureRenderer(table, tableFormat)
+
+	for _, i := range issues {
+		date := ""
+		if !i.Date.IsZero() {
+			date = timeToDate(&i.Date)
+		}
+
+		table.Append([]string{i.ISIN, i.Kind, date, i.Details})
+	}
+
+	table.Render()
+
+	if len(issues) > 0 {
+		return fmt.Errorf("%w: %d issues", ErrCheckFailed, len(issues))
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckStale(t *testing.T) {
+	// Friday evening
+	now := time.Date(2024, 3, 15, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		updatedAt time.Time
+		stale     bool
+	}{
+		{"never updated", time.Time{}, true},
+		{"today", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), false},
+		{"previous Friday", time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), false},
+		{"previous Thursday", time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC), true},
+		{"weekend before last", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		db := newTestDB(t, now)
+
+		if err := db.DB().Save(&ISIN{ID: "IE00B4L5Y983", UpdatedAt: tt.updatedAt}); err != nil {
+			t.Fatal(err)
+		}
+
+		issues, err := db.Check()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stale := len(issues) == 1 && issues[0].Kind == CheckStale
+		if stale != tt.stale || len(issues) > 1 {
+			t.Errorf("%s: issues are %+v, want stale %v", tt.name, issues, tt.stale)
+		}
+	}
+}
+
+func TestBusinessDaysBetween(t *testing.T) {
+	tests := []struct {
+		from, to time.Time
+		days     int
+	}{
+		{time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 18, 0, 0, 0, time.UTC), 0},
+		{time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), 5},
+		{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 29, 0, 0, 0, 0, time.UTC), 20},
+	}
+
+	for _, tt := range tests {
+		if got := businessDaysBetween(tt.from, tt.to); got != tt.days {
+			t.Errorf("business days from %s to %s are %d, want %d", timeToDate(&tt.from), timeToDate(&tt.to), got, tt.days)
+		}
+	}
+}
+
+func TestCheckSign(t *testing.T) {
+	now := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	db := newTestDB(t, now)
+
+	if err := db.DB().Save(&ISIN{ID: "BE0000000001", UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stored directly, like the trades from before the sign was validated
+	for _, tx := range []Transaction{
+		{ISIN: "BE0000000001", Type: TransactionTypeTrade, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), TotalShares: 10, TotalValue: 1000},
+		{ISIN: "BE0000000001", Type: TransactionTypeTrade, Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), TotalShares: -5, TotalValue: 600},
+		{ISIN: "BE0000000001", Type: TransactionTypeTrade, Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), TotalShares: 1},
+		{ISIN: "BE0000000001", Type: TransactionTypeDividend, Date: time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), TotalValue: 20},
+	} {
+		tx := tx
+		tx.GenerateUUID()
+
+		if err := db.DB().Save(&tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	issues, err := db.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issues) != 1 || issues[0].Kind != CheckSign || !issues[0].Date.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("issues are %+v, want the sale of 2024-03-04", issues)
+	}
+}
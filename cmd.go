@@ -1,48 +1,298 @@
 package main
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
+// annotationNoDB marks commands that manage their own database access
+const annotationNoDB = "no-db"
+
 func (a *App) EnableDebug(cmd *cobra.Command, args []string) {
 	if a.Debug {
 		a.logger.SetLevel(logrus.DebugLevel)
-		a.DB().logger.SetLevel(logrus.DebugLevel)
 	}
 }
 
+// LoadConfig reads the configuration file and applies the global flags
+func (a *App) LoadConfig() error {
+	c, err := LoadConfig(a.configFile)
+	if err != nil {
+		return err
+	}
+
+	if a.dbFile != "" {
+		c.DBFile = a.dbFile
+	}
+
+	if a.locale != "" {
+		c.Locale = a.locale
+	}
+
+	if a.timeout != 0 {
+		c.UpdateTimeout = a.timeout
+	}
+
+	c.HTTPReplayDir = a.httpReplay
+	c.HTTPRecordDir = a.httpRecord
+
+	if a.httpRecord != "" {
+		if err := StartRecording(a.httpRecord, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	a.config = c
+
+	return a.currency.Initialize(c.Locale)
+}
+
+func (a *App) PreRun(cmd *cobra.Command, args []string) error {
+	a.EnableDebug(cmd, args)
+
+	if err := a.LoadConfig(); err != nil {
+		return err
+	}
+
+	if _, ok := cmd.Annotations[annotationNoDB]; ok {
+		return nil
+	}
+
+	return a.OpenDB()
+}
+
 func (a *App) RootCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Short:            "track your investments",
-		PersistentPreRun: a.EnableDebug,
+		Short:             "track your investments",
+		PersistentPreRunE: a.PreRun,
 	}
 
 	cmd.PersistentFlags().BoolVarP(&a.Debug, "debug", "d", false, "debug mode")
+	cmd.PersistentFlags().StringVarP(&a.configFile, "config", "c", "", "configuration file (default $XDG_CONFIG_HOME/fintrk/config.yaml)")
+	cmd.PersistentFlags().StringVar(&a.dbFile, "db", "", "database file (default ~/.fintrk.db)")
+	cmd.PersistentFlags().StringVar(&a.locale, "locale", "", "locale to format values (default from environment)")
+	cmd.PersistentFlags().DurationVar(&a.timeout, "timeout", 0, "overall deadline for fetching data (default from config; none when 0)")
+	cmd.PersistentFlags().StringVar(&a.httpRecord, "http-record", "", "record all HTTP responses into this directory (debug)")
+	cmd.PersistentFlags().StringVar(&a.httpReplay, "http-replay", "", "replay HTTP responses from this directory instead of using the network (debug)")
 
 	cmd.AddCommand(a.UpdateValuationsCmd())
 	cmd.AddCommand(a.UpdateSharesCmd())
+	cmd.AddCommand(a.BackfillCmd())
 	cmd.AddCommand(a.ShowCmd())
 	cmd.AddCommand(a.ShowAtCmd())
 	cmd.AddCommand(a.ShowSinceCmd())
 	cmd.AddCommand(a.CreateTransactionCmd())
 	cmd.AddCommand(a.AddISINCmd())
+	cmd.AddCommand(a.SetPriceFieldCmd())
+	cmd.AddCommand(a.SetSourcesCmd())
+	cmd.AddCommand(a.SetPriceCmd())
+	cmd.AddCommand(a.ImportCmd())
+	cmd.AddCommand(a.ConflictsCmd())
+	cmd.AddCommand(a.CheckCmd())
+	cmd.AddCommand(a.QuarantineCmd())
+	cmd.AddCommand(a.CorporateActionsCmd())
+	cmd.AddCommand(a.DividendsCmd())
+	cmd.AddCommand(a.CashCmd())
+	cmd.AddCommand(a.AssetsCmd())
+	cmd.AddCommand(a.BondsCmd())
+	cmd.AddCommand(a.SetBenchmarkCmd())
+	cmd.AddCommand(a.CompareCmd())
+	cmd.AddCommand(a.NetWorthCmd())
+	cmd.AddCommand(a.FXCmd())
+	cmd.AddCommand(a.TaxCmd())
+	cmd.AddCommand(a.MetricsCmd())
+	cmd.AddCommand(a.DaemonCmd())
+	cmd.AddCommand(a.AlertsCmd())
+	cmd.AddCommand(a.ConfigCmd())
+	cmd.AddCommand(a.ClearCacheCmd())
 
 	return cmd
 }
 
 func (a *App) AddISINCmd() *cobra.Command {
-	source := ""
+	var (
+		pick int
+		list bool
+	)
+
+	opts := ISINOptions{}
 
 	cmd := &cobra.Command{
 		Use:   "add-isin",
-		Short: "add ISIN code to start tracking",
+		Short: "add ISIN code (or ticker, WKN, Valoren, SEDOL, or CRYPTO:<symbol>) to start tracking",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := a.UpdateContext(cmd.Context())
+			defer cancel()
+
+			sources := opts.Sources
+
+			for _, i := range args {
+				opts.Sources = sources
+				if len(opts.Sources) == 0 {
+					opts.Sources = []string{a.defaultSource(i)}
+				}
+
+				if list {
+					listings, err := a.candidateListings(ctx, i, opts)
+					if err != nil {
+						return err
+					}
+
+					a.ShowListings(a.TableFormat(""), listings)
+
+					continue
+				}
+
+				id, listing, err := a.ResolveISIN(ctx, i, opts, pick)
+				if err != nil {
+					return err
+				}
+
+				if err := a.DB().AddOrUpdateISIN(ctx, id, listing.Apply(opts)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&pick, "pick", 0, "listing to use when an identifier matches several (1-based)")
+	cmd.Flags().BoolVar(&list, "list", false, "only list the matching listings")
+	cmd.Flags().StringVar(&opts.Exchange, "exchange", "", "exchange of the listing to track")
+	cmd.Flags().StringSliceVarP(&opts.Sources, "source", "s", nil, "sources to fetch data from, in order of preference (default from config; coingecko for CRYPTO:)")
+	cmd.Flags().StringVarP(&opts.PriceField, "price-field", "p", "", "field of the valuations to use as price (default from config)")
+	cmd.Flags().StringVarP(&opts.Name, "name", "n", "", "name of the fund (for sources without metadata)")
+	cmd.Flags().StringVar(&opts.Currency, "currency", "", "currency of the listing to track")
+
+	return cmd
+}
+
+func (a *App) SetPriceFieldCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-price-field",
+		Short: "set the price field (open, close, high, low, typical, mid) of an ISIN; omit to use the global setting",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			field := ""
+			if len(args) == 2 {
+				field = args[1]
+			}
+
+			return a.DB().SetPriceField(args[0], field)
+		},
+	}
+}
+
+func (a *App) SetBenchmarkCmd() *cobra.Command {
+	var off bool
+
+	cmd := &cobra.Command{
+		Use:   "set-benchmark",
+		Short: "mark ISINs as benchmark to compare the portfolio with",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			for _, i := range args {
-				if err := a.DB().AddOrUpdateISIN(i, source); err != nil {
+				if err := a.DB().SetBenchmark(i, !off); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&off, "off", false, "unmark the ISINs as benchmark")
+
+	return cmd
+}
+
+func (a *App) CompareCmd() *cobra.Command {
+	var (
+		tableFormat string
+		benchmark   string
+		interval    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "compare the portfolio with the same cash flows invested in a benchmark, without dividends",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.ShowComparison(a.TableFormat(tableFormat), benchmark, interval)
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+	cmd.Flags().StringVar(&benchmark, "benchmark", "", "ISIN to compare with (default the one marked as benchmark)")
+	cmd.Flags().StringVar(&interval, "interval", "month", "interval of the comparison (day, week, month, year)")
+
+	return cmd
+}
+
+func (a *App) SetSourcesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-sources",
+		Short: "set the sources of an ISIN, in order of preference",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.DB().SetSources(args[0], args[1:])
+		},
+	}
+}
+
+func (a *App) SetPriceCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-price",
+		Short: "set the price of an ISIN at a date (ISIN, YYYY-MM-DD, value)",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := time.Parse("2006-01-02", args[1])
+			if err != nil {
+				return err
+			}
+
+			v, err := strconv.ParseFloat(args[2], 64)
+			if err != nil {
+				return err
+			}
+
+			return a.DB().SetPrice(args[0], d, v)
+		},
+	}
+}
+
+func (a *App) ImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "import data from files",
+	}
+
+	cmd.AddCommand(a.ImportPricesCmd())
+
+	return cmd
+}
+
+func (a *App) ImportPricesCmd() *cobra.Command {
+	var isinID, delimiter string
+
+	cmd := &cobra.Command{
+		Use:   "prices",
+		Short: "import prices from CSV files (date,value or date,open,high,low,close; optional isin column)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d := []rune(delimiter)
+			if len(d) != 1 {
+				return ErrCSVDelimiter
+			}
+
+			for _, f := range args {
+				if err := a.DB().ImportPricesCSV(f, isinID, d[0]); err != nil {
 					return err
 				}
 			}
@@ -51,7 +301,46 @@ func (a *App) AddISINCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&source, "source", "s", "FT", "source to fetch data from")
+	cmd.Flags().StringVarP(&isinID, "isin", "i", "", "ISIN of the prices when the file has no isin column")
+	cmd.Flags().StringVar(&delimiter, "delimiter", ",", "field delimiter")
+
+	return cmd
+}
+
+func (a *App) ConflictsCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "conflicts",
+		Short: "show dates on which sources disagree on the price",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.ShowConflicts(a.TableFormat(tableFormat))
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) DividendsCmd() *cobra.Command {
+	var (
+		tableFormat string
+		base        bool
+		year        int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dividends",
+		Short: "show dividend payouts per ISIN, year and month, and the dividend yields",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.ShowDividends(a.TableFormat(tableFormat), base, year)
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+	cmd.Flags().BoolVar(&base, "base", false, "also show the net amounts in the base currency")
+	cmd.Flags().IntVar(&year, "year", 0, "only show the payouts of this year")
 
 	return cmd
 }
@@ -63,11 +352,11 @@ func (a *App) ShowCmd() *cobra.Command {
 		Use:   "show",
 		Short: "show current state of tracked funds",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return a.ShowCurrentState(tableFormat)
+			return a.ShowCurrentState(a.TableFormat(tableFormat))
 		},
 	}
 
-	cmd.Flags().StringVarP(&tableFormat, "format", "f", "ascii", "rendering format (ascii, markdown)")
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
 
 	return cmd
 }
@@ -85,11 +374,11 @@ func (a *App) ShowAtCmd() *cobra.Command {
 				return err
 			}
 
-			return a.ShowStateAt(tableFormat, d)
+			return a.ShowStateAt(a.TableFormat(tableFormat), d)
 		},
 	}
 
-	cmd.Flags().StringVarP(&tableFormat, "format", "f", "ascii", "rendering format (ascii, markdown)")
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
 
 	return cmd
 }
@@ -107,23 +396,85 @@ func (a *App) ShowSinceCmd() *cobra.Command {
 				return err
 			}
 
-			return a.ShowStateSince(tableFormat, d)
+			return a.ShowStateSince(a.TableFormat(tableFormat), d)
 		},
 	}
 
-	cmd.Flags().StringVarP(&tableFormat, "format", "f", "ascii", "rendering format (ascii, markdown)")
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
 
 	return cmd
 }
 
 func (a *App) UpdateValuationsCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		concurrency         int
+		tableFormat, failOn string
+	)
+
+	cmd := &cobra.Command{
 		Use:   "update",
 		Short: "update all tracked funds",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return a.DB().UpdateValuationsAll()
+			if concurrency > 0 {
+				a.config.Concurrency = concurrency
+			}
+
+			if err := ValidateFailOn(failOn); err != nil {
+				return err
+			}
+
+			ctx, cancel := a.UpdateContext(cmd.Context())
+			defer cancel()
+
+			results, err := a.DB().UpdateValuationsAll(ctx)
+			if err != nil {
+				return err
+			}
+
+			a.ShowUpdateResults(a.TableFormat(tableFormat), results)
+
+			cmd.SilenceUsage = true
+
+			return results.Check(failOn)
+		},
+	}
+
+	cmd.Flags().IntVar(&concurrency, "concurrency", 0, "number of funds to update at the same time (default from config)")
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+	cmd.Flags().StringVar(&failOn, "fail-on", FailOnAny, "exit with an error when any, all or none of the funds failed to update")
+
+	return cmd
+}
+
+func (a *App) BackfillCmd() *cobra.Command {
+	since := ""
+
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "fetch the full history of a fund",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var d time.Time
+
+			if since != "" {
+				parsed, err := time.Parse("2006-01-02", since)
+				if err != nil {
+					return err
+				}
+
+				d = parsed
+			}
+
+			ctx, cancel := a.UpdateContext(cmd.Context())
+			defer cancel()
+
+			return a.DB().Backfill(ctx, args[0], d)
 		},
 	}
+
+	cmd.Flags().StringVar(&since, "since", "", "first date to fetch (YYYY-MM-DD; empty for the first transaction)")
+
+	return cmd
 }
 
 func (a *App) UpdateSharesCmd() *cobra.Command {
@@ -149,6 +500,10 @@ func (a *App) CreateTransactionCmd() *cobra.Command {
 				return err
 			}
 
+			if !cmd.Flags().Changed("account") {
+				transaction.Account = a.config.CashAccount
+			}
+
 			if err := a.DB().CreateTransaction(&transaction); err != nil {
 				return err
 			}
@@ -160,13 +515,120 @@ func (a *App) CreateTransactionCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&txDate, "date", "d", "", "transaction date (YYYY-MM-DD; empty for today)")
+	cmd.Flags().StringVar(&txDate, "date", "", "transaction date (YYYY-MM-DD; empty for today)")
 	cmd.Flags().StringVarP(&transaction.ISIN, "isin", "i", "", "ISIN")
-	cmd.Flags().Float64VarP(&transaction.TotalShares, "shares", "s", 0, "total amount of shares")
-	cmd.Flags().Float64VarP(&transaction.TotalValue, "value", "v", 0, "total amount of value")
+	cmd.Flags().StringVarP(&transaction.Type, "type", "t", TransactionTypeTrade, "transaction type (trade, dividend)")
+	cmd.Flags().Float64VarP(&transaction.TotalShares, "shares", "s", 0, "total amount of shares (trades only)")
+	cmd.Flags().Float64VarP(&transaction.TotalValue, "value", "v", 0, "total amount of value, negative for a sale like the shares (gross for dividends)")
+	cmd.Flags().Float64Var(&transaction.Tax, "tax", 0, "tax withheld at the source")
+	cmd.Flags().Float64Var(&transaction.Fee, "fee", 0, "broker fee, on top of the value")
+	cmd.Flags().StringVar(&transaction.Account, "account", "", "cash account to pay from or into (default from config; empty for none)")
+
+	cmd.MarkFlagRequired("isin") //nolint:errcheck
+
+	return cmd
+}
+
+func (a *App) MetricsCmd() *cobra.Command {
+	listen := ""
+
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "serve Prometheus metrics of tracked funds",
+		Long: `Serve Prometheus metrics of tracked funds.
+
+The database is opened for every scrape. While another process holds it, for
+example during an update, the previous metrics are served again, and the
+first scrape fails. Use 'daemon --metrics-listen' to serve the metrics of the
+process that updates.`,
+		Annotations: map[string]string{annotationNoDB: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.ServeMetrics(listen)
+		},
+	}
+
+	cmd.Flags().StringVarP(&listen, "listen", "l", ":9283", "address to listen on")
+
+	return cmd
+}
+
+func (a *App) DaemonCmd() *cobra.Command {
+	var (
+		times, days   []string
+		zone          string
+		runNow        bool
+		metricsListen string
+		concurrency   int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "keep running and update all tracked funds on a schedule",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := NewSchedule(times, days, zone)
+			if err != nil {
+				return err
+			}
+
+			if concurrency > 0 {
+				a.config.Concurrency = concurrency
+			}
 
-	cmd.MarkFlagRequired("isin")   //nolint:errcheck
-	cmd.MarkFlagRequired("shares") //nolint:errcheck
+			return a.RunDaemon(s, runNow, metricsListen)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&times, "at", "a", []string{"18:00"}, "times of day to update (HH:MM)")
+	cmd.Flags().StringSliceVar(&days, "days", []string{"mon-fri"}, "days of the week to update (eg. mon-fri,sun)")
+	cmd.Flags().StringVarP(&zone, "timezone", "z", "Local", "time zone of the schedule")
+	cmd.Flags().BoolVar(&runNow, "now", false, "update immediately after starting")
+	cmd.Flags().StringVar(&metricsListen, "metrics-listen", "", "also serve Prometheus metrics on this address")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 0, "number of funds to update at the same time (default from config)")
 
 	return cmd
 }
+
+func (a *App) ConfigCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "config",
+		Short:       "show the effective configuration",
+		Annotations: map[string]string{annotationNoDB: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := *a.config
+			if c.SMTP.Password != "" {
+				c.SMTP.Password = "********"
+			}
+
+			// Copy the sources, the configuration in use keeps its keys
+			c.Sources = make(map[string]SourceConfig, len(a.config.Sources))
+
+			for name, s := range a.config.Sources {
+				if s.APIKey != "" {
+					s.APIKey = "********"
+				}
+
+				c.Sources[name] = s
+			}
+
+			out, err := yaml.Marshal(&c)
+			if err != nil {
+				return err
+			}
+
+			fmt.Print(string(out))
+
+			return nil
+		},
+	}
+}
+
+func (a *App) ClearCacheCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "clear-cache",
+		Short:       "remove all cached HTTP responses",
+		Annotations: map[string]string{annotationNoDB: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ClearHTTPCache(a.config.CacheDir)
+		},
+	}
+}
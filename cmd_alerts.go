@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var ErrAlertCondition = errors.New("exactly one of --drop, --above or --below is required")
+
+func (a *App) AlertsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alerts",
+		Short: "manage price alerts",
+	}
+
+	cmd.AddCommand(a.AlertsAddCmd())
+	cmd.AddCommand(a.AlertsListCmd())
+	cmd.AddCommand(a.AlertsDeleteCmd())
+
+	return cmd
+}
+
+func (a *App) AlertsAddCmd() *cobra.Command {
+	rule := AlertRule{}
+
+	var drop, above, below float64
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "add an alert for an ISIN, or for the portfolio total when no ISIN is given",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conditions := map[string]float64{
+				AlertConditionDrop:  drop,
+				AlertConditionAbove: above,
+				AlertConditionBelow: below,
+			}
+
+			for c, v := range conditions {
+				if !cmd.Flags().Changed(c) {
+					continue
+				}
+
+				if rule.Condition != "" {
+					return ErrAlertCondition
+				}
+
+				rule.Condition = c
+				rule.Threshold = v
+			}
+
+			if rule.Condition == "" {
+				return ErrAlertCondition
+			}
+
+			if rule.ISIN == "" && rule.Nomination == "" {
+				rule.Nomination = a.config.BaseCurrency
+			}
+
+			if err := a.DB().CreateAlertRule(&rule); err != nil {
+				return err
+			}
+
+			a.logger.Infof("Created alert: %s", rule.String())
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&rule.ISIN, "isin", "i", "", "ISIN (empty for the portfolio total)")
+	cmd.Flags().StringVarP(&rule.Nomination, "nomination", "n", "", "nomination of the portfolio total (default base currency)")
+	cmd.Flags().Float64Var(&drop, AlertConditionDrop, 0, "alert when the value drops more than this percentage in a day")
+	cmd.Flags().Float64Var(&above, AlertConditionAbove, 0, "alert when the value crosses above this threshold")
+	cmd.Flags().Float64Var(&below, AlertConditionBelow, 0, "alert when the value crosses below this threshold")
+	cmd.Flags().StringVar(&rule.Notifier, "notify", NotifierWebhook, "notifier (webhook, email, command)")
+	cmd.Flags().StringVarP(&rule.Target, "target", "t", "", "URL, e-mail address or shell command to notify")
+
+	cmd.MarkFlagRequired("target") //nolint:errcheck
+
+	return cmd
+}
+
+func (a *App) AlertsListCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list all alerts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules, err := a.DB().GetAllAlertRules()
+			if err != nil {
+				return err
+			}
+
+			sort.Slice(rules, func(i, j int) bool {
+				return rules[i].Subject() < rules[j].Subject()
+			})
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"ID", "Subject", "Condition", "Threshold", "Notifier", "Target", "Active", "Last fired"})
+			configureRenderer(table, a.TableFormat(tableFormat))
+
+			for _, r := range rules {
+				lastFired := ""
+				if !r.LastFired.IsZero() {
+					lastFired = timeToDate(&r.LastFired)
+				}
+
+				table.Append([]string{
+					r.UUID.String(), r.Subject(), r.Condition, fmt.Sprintf("%.2f", r.Threshold),
+					r.Notifier, r.Target, fmt.Sprintf("%t", r.Active), lastFired,
+				})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) AlertsDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete",
+		Short: "delete alerts",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, id := range args {
+				if err := a.DB().DeleteAlertRule(id); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
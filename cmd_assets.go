@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func (a *App) AssetsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "assets",
+		Short: "manage manually valued assets and liabilities",
+	}
+
+	cmd.AddCommand(a.AssetsAddCmd())
+	cmd.AddCommand(a.AssetsListCmd())
+	cmd.AddCommand(a.AssetsValueCmd())
+	cmd.AddCommand(a.AssetsHistoryCmd())
+	cmd.AddCommand(a.AssetsDeleteCmd())
+
+	return cmd
+}
+
+func (a *App) AssetsAddCmd() *cobra.Command {
+	asset := Asset{}
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "add an asset or liability (NAME)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			asset.Name = args[0]
+
+			if asset.Currency == "" {
+				asset.Currency = a.config.BaseCurrency
+			}
+
+			return a.DB().CreateAsset(&asset)
+		},
+	}
+
+	cmd.Flags().StringVar(&asset.Kind, "kind", AssetKindAsset, "asset or liability")
+	cmd.Flags().StringVar(&asset.Category, "category", "", "free category, like real-estate, vehicle or mortgage")
+	cmd.Flags().StringVar(&asset.Currency, "currency", "", "currency of the values (default base currency)")
+
+	return cmd
+}
+
+func (a *App) AssetsListCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list all assets and liabilities with their last value",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			assets, err := a.DB().GetAllAssets()
+			if err != nil {
+				return err
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Name", "Kind", "Category", "Currency", "Last update", "Value"})
+			configureRenderer(table, a.TableFormat(tableFormat))
+
+			for _, asset := range assets {
+				v, d, err := a.DB().AssetValueAt(asset.Name, a.DB().Now())
+				if err != nil {
+					return err
+				}
+
+				date := ""
+				if !d.IsZero() {
+					date = timeToDate(&d)
+				}
+
+				table.Append([]string{asset.Name, asset.Kind, asset.Category, asset.Currency, date, a.localize(asset.Currency, v)})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) AssetsValueCmd() *cobra.Command {
+	var date string
+
+	cmd := &cobra.Command{
+		Use:   "value",
+		Short: "set the value of an asset, or the amount owed on a liability (NAME, VALUE)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				return err
+			}
+
+			d := a.DB().Today()
+
+			if date != "" {
+				if d, err = time.Parse("2006-01-02", date); err != nil {
+					return err
+				}
+			}
+
+			return a.DB().SetAssetValue(args[0], d, value)
+		},
+	}
+
+	cmd.Flags().StringVar(&date, "date", "", "date of the value (YYYY-MM-DD; empty for today)")
+
+	return cmd
+}
+
+func (a *App) AssetsHistoryCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "show all values of an asset or liability (NAME)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			asset, err := a.DB().GetAsset(args[0])
+			if err != nil {
+				return fmt.Errorf("%w: asset '%s'", err, args[0])
+			}
+
+			values, err := a.DB().GetAssetValues(asset.Name)
+			if err != nil {
+				return err
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Date", "Value"})
+			configureRenderer(table, a.TableFormat(tableFormat))
+
+			for _, v := range values {
+				table.Append([]string{timeToDate(&v.Date), a.localize(asset.Currency, v.Close)})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) AssetsDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete",
+		Short: "delete assets or liabilities and their values",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range args {
+				if err := a.DB().DeleteAsset(name); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+func (a *App) NetWorthCmd() *cobra.Command {
+	var (
+		tableFormat string
+		date        string
+		history     bool
+		since       string
+		interval    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "networth",
+		Short: "show the balance sheet and net worth in the base currency",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if history {
+				start := a.DB().Now().AddDate(-1, 0, 0)
+
+				if since != "" {
+					var err error
+					if start, err = time.Parse("2006-01-02", since); err != nil {
+						return err
+					}
+				}
+
+				return a.ShowNetWorthHistory(a.TableFormat(tableFormat), start, interval)
+			}
+
+			d := a.DB().Now()
+
+			if date != "" {
+				var err error
+				if d, err = time.Parse("2006-01-02", date); err != nil {
+					return err
+				}
+			}
+
+			return a.ShowBalanceSheet(a.TableFormat(tableFormat), d)
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+	cmd.Flags().StringVar(&date, "date", "", "date of the balance sheet (YYYY-MM-DD; empty for today)")
+	cmd.Flags().BoolVar(&history, "history", false, "show the net worth over time")
+	cmd.Flags().StringVar(&since, "since", "", "start of the history (YYYY-MM-DD; default a year ago)")
+	cmd.Flags().StringVar(&interval, "interval", "month", "interval of the history (day, week, month, year)")
+
+	return cmd
+}
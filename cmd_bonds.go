@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func (a *App) BondsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bonds",
+		Short: "manage individual bonds",
+	}
+
+	cmd.AddCommand(a.BondsSetCmd())
+	cmd.AddCommand(a.BondsClearCmd())
+	cmd.AddCommand(a.BondsCouponsCmd())
+
+	return cmd
+}
+
+func (a *App) BondsSetCmd() *cobra.Command {
+	var (
+		terms    BondTerms
+		maturity string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "set the terms of a bond (ISIN); its shares are the number of bonds, its price a percentage of the nominal",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+
+			if terms.Maturity, err = time.Parse("2006-01-02", maturity); err != nil {
+				return err
+			}
+
+			return a.DB().SetBondTerms(args[0], &terms)
+		},
+	}
+
+	cmd.Flags().Float64Var(&terms.Nominal, "nominal", 100, "nominal value of one bond")
+	cmd.Flags().Float64Var(&terms.Coupon, "coupon", 0, "coupon rate, in percent of the nominal per year")
+	cmd.Flags().IntVar(&terms.Frequency, "frequency", 1, "coupon payments per year (1, 2, 4, 12)")
+	cmd.Flags().StringVar(&maturity, "maturity", "", "maturity date (YYYY-MM-DD)")
+
+	cmd.MarkFlagRequired("maturity") //nolint:errcheck
+
+	return cmd
+}
+
+func (a *App) BondsClearCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "treat ISINs as regular securities again",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, i := range args {
+				if err := a.DB().SetBondTerms(i, nil); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+func (a *App) BondsCouponsCmd() *cobra.Command {
+	var (
+		tableFormat string
+		until       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "coupons",
+		Short: "show the expected coupons and redemptions of the bonds held",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			now := a.DB().Now()
+			end := now.AddDate(1, 0, 0)
+
+			if until != "" {
+				var err error
+				if end, err = time.Parse("2006-01-02", until); err != nil {
+					return err
+				}
+			}
+
+			flows, err := a.DB().BondCashFlows(now, end)
+			if err != nil {
+				return err
+			}
+
+			isins, err := a.DB().GetAllISIN()
+			if err != nil {
+				return err
+			}
+
+			byID := map[string]*ISIN{}
+			for k := range isins {
+				byID[isins[k].ID] = &isins[k]
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Date", "ISIN", "Name", "Type", "Nom", "Amount"})
+			configureRenderer(table, a.TableFormat(tableFormat))
+
+			totals := map[string]float64{}
+
+			for _, f := range flows {
+				i := byID[f.ISIN]
+				totals[i.Nomination] += f.Amount
+
+				table.Append([]string{timeToDate(&f.Date), f.ISIN, i.Name, f.Type, i.Nomination, a.localize(i.Nomination, f.Amount)})
+			}
+
+			for nom, t := range totals {
+				table.Append([]string{"Total", "", "", "", nom, a.localize(nom, t)})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+	cmd.Flags().StringVar(&until, "until", "", "last date to show (YYYY-MM-DD; default a year from now)")
+
+	return cmd
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func (a *App) CashCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cash",
+		Short: "manage cash accounts",
+	}
+
+	cmd.AddCommand(a.CashAddCmd())
+	cmd.AddCommand(a.CashListCmd())
+	cmd.AddCommand(a.CashDeleteCmd())
+	cmd.AddCommand(a.CashMovementCmd(CashMovementDeposit, "deposit", "put money into a cash account"))
+	cmd.AddCommand(a.CashMovementCmd(CashMovementWithdrawal, "withdraw", "take money out of a cash account"))
+	cmd.AddCommand(a.CashMovementCmd(CashMovementInterest, "interest", "add interest to a cash account"))
+	cmd.AddCommand(a.CashHistoryCmd())
+	cmd.AddCommand(a.CashDeleteMovementCmd())
+
+	return cmd
+}
+
+func (a *App) CashAddCmd() *cobra.Command {
+	var currency string
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "add a cash account (NAME)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if currency == "" {
+				currency = a.config.BaseCurrency
+			}
+
+			return a.DB().CreateCashAccount(args[0], currency)
+		},
+	}
+
+	cmd.Flags().StringVar(&currency, "currency", "", "currency of the account (default base currency)")
+
+	return cmd
+}
+
+func (a *App) CashListCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list all cash accounts with their balance",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			balances, err := a.cashBalances(a.DB().Now())
+			if err != nil {
+				return err
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Name", "Currency", "Created", "Balance"})
+			configureRenderer(table, a.TableFormat(tableFormat))
+
+			for _, c := range balances {
+				table.Append([]string{
+					c.Account.Name, c.Account.Currency, timeToDate(&c.Account.Created), a.localize(c.Account.Currency, c.Balance),
+				})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) CashDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete",
+		Short: "delete cash accounts and their movements",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range args {
+				if err := a.DB().DeleteCashAccount(name); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+func (a *App) CashMovementCmd(kind, use, short string) *cobra.Command {
+	var date, note string
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short + " (NAME, AMOUNT)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			amount, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				return err
+			}
+
+			m := CashMovement{Account: args[0], Type: kind, Amount: amount, Note: note, Date: a.DB().Today()}
+
+			if date != "" {
+				if m.Date, err = time.Parse("2006-01-02", date); err != nil {
+					return err
+				}
+			}
+
+			if err := a.DB().AddCashMovement(&m); err != nil {
+				return err
+			}
+
+			a.logger.Infof("Added %s of %.2f to '%s' at %s", kind, math.Abs(m.Amount), m.Account, timeToDate(&m.Date))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&date, "date", "", "date (YYYY-MM-DD; empty for today)")
+	cmd.Flags().StringVar(&note, "note", "", "description")
+
+	return cmd
+}
+
+func (a *App) CashHistoryCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "show the movements of a cash account, including its transactions (NAME)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			account, err := a.DB().GetCashAccount(args[0])
+			if err != nil {
+				return fmt.Errorf("%w: cash account '%s'", err, args[0])
+			}
+
+			movements, err := a.DB().CashMovements(account, a.DB().Now())
+			if err != nil {
+				return err
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"ID", "Date", "Type", "Note", "Amount", "Balance"})
+			configureRenderer(table, a.TableFormat(tableFormat))
+
+			var balance float64
+
+			for _, m := range movements {
+				balance += m.Amount
+
+				table.Append([]string{
+					m.UUID.String(), timeToDate(&m.Date), m.Type, m.Note,
+					a.localize(account.Currency, m.Amount), a.localize(account.Currency, balance),
+				})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) CashDeleteMovementCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete-movement",
+		Short: "delete deposits, withdrawals or interest payments (ID)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, id := range args {
+				if err := a.DB().DeleteCashMovement(id); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func (a *App) CorporateActionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "corporate-actions",
+		Short: "manage splits and mergers",
+	}
+
+	cmd.AddCommand(a.CorporateActionsSplitCmd())
+	cmd.AddCommand(a.CorporateActionsMergerCmd())
+	cmd.AddCommand(a.CorporateActionsListCmd())
+	cmd.AddCommand(a.CorporateActionsDeleteCmd())
+
+	return cmd
+}
+
+func (a *App) CorporateActionsSplitCmd() *cobra.Command {
+	var pricesAdjusted bool
+
+	cmd := &cobra.Command{
+		Use:   "split",
+		Short: "register a split (ISIN, YYYY-MM-DD, new shares per old share)",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := CorporateAction{
+				ISIN:           args[0],
+				Type:           CorporateActionSplit,
+				PricesAdjusted: pricesAdjusted,
+			}
+
+			if err := parseCorporateAction(&c, args[1], args[2]); err != nil {
+				return err
+			}
+
+			return a.createCorporateAction(&c)
+		},
+	}
+
+	cmd.Flags().BoolVar(&pricesAdjusted, "prices-adjusted", false, "the sources already report split-adjusted historical prices")
+
+	return cmd
+}
+
+func (a *App) CorporateActionsMergerCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "merger",
+		Short: "register a merger or ISIN change (old ISIN, new ISIN, YYYY-MM-DD, new shares per old share)",
+		Args:  cobra.ExactArgs(4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := CorporateAction{
+				ISIN:    args[0],
+				NewISIN: args[1],
+				Type:    CorporateActionMerger,
+			}
+
+			if err := parseCorporateAction(&c, args[2], args[3]); err != nil {
+				return err
+			}
+
+			return a.createCorporateAction(&c)
+		},
+	}
+}
+
+func parseCorporateAction(c *CorporateAction, date string, ratio string) error {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return err
+	}
+
+	r, err := strconv.ParseFloat(ratio, 64)
+	if err != nil {
+		return err
+	}
+
+	c.Date = d
+	c.Ratio = r
+
+	return nil
+}
+
+func (a *App) createCorporateAction(c *CorporateAction) error {
+	if err := a.DB().CreateCorporateAction(c); err != nil {
+		return err
+	}
+
+	a.logger.Infof("Registered %s", c.String())
+
+	return nil
+}
+
+func (a *App) CorporateActionsListCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list all corporate actions",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			actions, err := a.DB().GetAllCorporateActions()
+			if err != nil {
+				return err
+			}
+
+			sort.Slice(actions, func(i, j int) bool {
+				return actions[i].Date.Before(actions[j].Date)
+			})
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"ID", "Date", "Type", "ISIN", "New ISIN", "Ratio", "Prices adjusted"})
+			configureRenderer(table, a.TableFormat(tableFormat))
+
+			for _, c := range actions {
+				table.Append([]string{
+					c.UUID.String(), timeToDate(&c.Date), c.Type, c.ISIN, c.NewISIN,
+					fmt.Sprintf("%g", c.Ratio), fmt.Sprintf("%t", c.PricesAdjusted),
+				})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) CorporateActionsDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete",
+		Short: "delete corporate actions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, id := range args {
+				if err := a.DB().DeleteCorporateAction(id); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
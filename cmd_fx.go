@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func (a *App) FXCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fx",
+		Short: "manage exchange rates",
+	}
+
+	cmd.AddCommand(a.FXUpdateCmd())
+	cmd.AddCommand(a.FXSetCmd())
+
+	return cmd
+}
+
+func (a *App) FXUpdateCmd() *cobra.Command {
+	var history bool
+
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "fetch the euro reference rates of the European Central Bank",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := a.UpdateContext(cmd.Context())
+			defer cancel()
+
+			count, err := a.DB().UpdateFXFromECB(ctx, history)
+			if err != nil {
+				return err
+			}
+
+			a.logger.Infof("Stored %d exchange rates", count)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&history, "history", false, "fetch all rates since 1999 instead of the last 90 days")
+
+	return cmd
+}
+
+func (a *App) FXSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set",
+		Short: "set an exchange rate (from currency, to currency, YYYY-MM-DD, amount of to per from)",
+		Args:  cobra.ExactArgs(4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := time.Parse("2006-01-02", args[2])
+			if err != nil {
+				return err
+			}
+
+			rate, err := strconv.ParseFloat(args[3], 64)
+			if err != nil {
+				return err
+			}
+
+			return a.DB().SetFXRate(args[0], args[1], d, rate)
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func (a *App) CheckCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "list stale funds, quarantined prices, conflicts and trades with the wrong sign; fails when there are any",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			return a.ShowCheck(a.TableFormat(tableFormat))
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) QuarantineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quarantine",
+		Short: "manage prices rejected by validation",
+	}
+
+	cmd.AddCommand(a.QuarantineListCmd())
+	cmd.AddCommand(a.QuarantineAcceptCmd())
+	cmd.AddCommand(a.QuarantineDropCmd())
+
+	return cmd
+}
+
+func (a *App) QuarantineListCmd() *cobra.Command {
+	var tableFormat string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list quarantined prices",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			quarantine, err := a.DB().GetQuarantine()
+			if err != nil {
+				return err
+			}
+
+			sort.Slice(quarantine, func(i, j int) bool {
+				return quarantine[i].ID < quarantine[j].ID
+			})
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"ID", "ISIN", "Date", "Source", "Open", "Close", "Reason", "Detected"})
+			configureRenderer(table, a.TableFormat(tableFormat))
+
+			for _, qv := range quarantine {
+				v := qv.Valuation
+
+				table.Append([]string{
+					qv.ID, qv.ISIN, timeToDate(&v.Date), v.Source,
+					fmt.Sprintf("%.4f", v.Open), fmt.Sprintf("%.4f", v.Close), qv.Reason, timeToDate(&qv.Detected),
+				})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+
+	return cmd
+}
+
+func (a *App) QuarantineAcceptCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "accept",
+		Short: "accept quarantined prices as valid",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, id := range args {
+				if err := a.DB().AcceptQuarantined(id); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+func (a *App) QuarantineDropCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "drop",
+		Short: "discard quarantined prices",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, id := range args {
+				if err := a.DB().DropQuarantined(id); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
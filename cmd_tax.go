@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func (a *App) TaxCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tax",
+		Short: "tax reports",
+	}
+
+	cmd.AddCommand(a.TaxBECmd())
+	cmd.AddCommand(a.TaxLotsCmd())
+
+	return cmd
+}
+
+func (a *App) TaxBECmd() *cobra.Command {
+	var (
+		tableFormat string
+		year        int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "be",
+		Short: "show the Belgian stock exchange tax (TOB) and Reynders tax, in EUR",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.ShowTaxBE(a.TableFormat(tableFormat), year)
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+	cmd.Flags().IntVar(&year, "year", 0, "only show this year")
+
+	cmd.AddCommand(a.TaxBESetCmd())
+
+	return cmd
+}
+
+func (a *App) TaxBESetCmd() *cobra.Command {
+	var (
+		category       string
+		bondPercentage float64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "set the tax category and bond percentage of ISINs",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var bonds *float64
+			if cmd.Flags().Changed("bond-percentage") {
+				bonds = &bondPercentage
+			}
+
+			for _, i := range args {
+				if err := a.DB().SetTaxInfo(i, category, bonds); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&category, "category", "", "tax category (share, bond, etf-eea, etf, fund-distributing, fund-accumulating, exempt)")
+	cmd.Flags().Float64Var(&bondPercentage, "bond-percentage", 0, "percentage of the fund invested in bonds (for the Reynders tax)")
+
+	return cmd
+}
+
+func (a *App) TaxLotsCmd() *cobra.Command {
+	var (
+		tableFormat string
+		year        int
+		method      string
+		csvFile     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "lots",
+		Short: "show every sale per lot, with holding period, proceeds, cost and gain",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if method == "" {
+				method = a.config.LotMethod
+			}
+
+			return a.ShowTaxLots(a.TableFormat(tableFormat), year, method, csvFile)
+		},
+	}
+
+	cmd.Flags().StringVarP(&tableFormat, "format", "f", "", "rendering format (ascii, markdown; default from config)")
+	cmd.Flags().IntVar(&year, "year", 0, "only show sales of this year")
+	cmd.Flags().StringVar(&method, "method", "", "lot matching method (fifo, lifo, hifo; default from config)")
+	cmd.Flags().StringVar(&csvFile, "csv", "", "also write the sales to this CSV file ('-' for stdout)")
+
+	return cmd
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds all settings; it is read from a YAML file and can be
+// overridden by environment variables and command line flags
+type Config struct {
+	DBFile        string                  `yaml:"db_file"`
+	DefaultSource string                  `yaml:"default_source"`
+	Concurrency   int                     `yaml:"concurrency"`
+	BaseCurrency  string                  `yaml:"base_currency"`
+	Locale        string                  `yaml:"locale"`
+	Format        string                  `yaml:"format"`
+	PriceField    string                  `yaml:"price_field"`
+	Tolerance     float64                 `yaml:"source_tolerance"`
+	MaxChange     float64                 `yaml:"max_daily_change"`
+	LotMethod     string                  `yaml:"lot_method"`
+	CashAccount   string                  `yaml:"cash_account"`
+	StaleDays     int                     `yaml:"stale_days"`
+	UpdateTimeout time.Duration           `yaml:"update_timeout"`
+	CacheDir      string                  `yaml:"cache_dir"`
+	HTTP          HTTPConfig              `yaml:"http"`
+	SMTP          SMTPConfig              `yaml:"smtp"`
+	TaxBE         TaxBEConfig             `yaml:"tax_be"`
+	Sources       map[string]SourceConfig `yaml:"sources"`
+
+	HTTPRecordDir string `yaml:"-"`
+	HTTPReplayDir string `yaml:"-"`
+}
+
+// HTTPConfig configures the HTTP clients used to fetch data; zero values
+// are inherited from the global settings. RateLimit is in requests per
+// second, CacheTTL applies to price data and MetaCacheTTL to metadata
+// lookups, which rarely change
+type HTTPConfig struct {
+	Timeout      time.Duration `yaml:"timeout,omitempty"`
+	RetryMax     int           `yaml:"retry_max,omitempty"`
+	RetryWaitMin time.Duration `yaml:"retry_wait_min,omitempty"`
+	RetryWaitMax time.Duration `yaml:"retry_wait_max,omitempty"`
+	RateLimit    float64       `yaml:"rate_limit,omitempty"`
+	CacheTTL     time.Duration `yaml:"cache_ttl,omitempty"`
+	MetaCacheTTL time.Duration `yaml:"meta_cache_ttl,omitempty"`
+}
+
+// TaxBEConfig holds the Belgian tax rates; TOB rates are per tax category of
+// an ISIN, Reynders tax applies to funds with more bonds than the threshold
+// (all in percent)
+type TaxBEConfig struct {
+	TOB               map[string]TOBRate `yaml:"tob"`
+	ReyndersRate      float64            `yaml:"reynders_rate"`
+	ReyndersThreshold float64            `yaml:"reynders_threshold"`
+}
+
+// TOBRate is the stock exchange tax on a transaction, capped at Max (EUR);
+// some categories are only taxed when selling
+type TOBRate struct {
+	Rate     float64 `yaml:"rate"`
+	Max      float64 `yaml:"max"`
+	SellOnly bool    `yaml:"sell_only,omitempty"`
+}
+
+// SourceConfig holds the settings of a single data source; sources with a
+// command are external command sources, the API key is sent to sources that
+// accept one
+type SourceConfig struct {
+	HTTP    HTTPConfig `yaml:"http,omitempty"`
+	Command string     `yaml:"command,omitempty"`
+	Args    []string   `yaml:"args,omitempty"`
+	APIKey  string     `yaml:"api_key,omitempty"`
+}
+
+func DefaultConfig() *Config {
+	c := Config{
+		DefaultSource: DataSourceFT,
+		Concurrency:   8,
+		Format:        "ascii",
+		PriceField:    PriceFieldOpen,
+		Tolerance:     1,
+		MaxChange:     50,
+		LotMethod:     LotMethodFIFO,
+		StaleDays:     5,
+		HTTP: HTTPConfig{
+			Timeout:      30 * time.Second,
+			RetryMax:     4,
+			RetryWaitMin: 1 * time.Second,
+			RetryWaitMax: 30 * time.Second,
+			MetaCacheTTL: 72 * time.Hour,
+		},
+		TaxBE: TaxBEConfig{
+			TOB: map[string]TOBRate{
+				TaxCategoryShare:     {Rate: 0.35, Max: 1600},
+				TaxCategoryBond:      {Rate: 0.12, Max: 1300},
+				TaxCategoryETFEEA:    {Rate: 0.12, Max: 1300},
+				TaxCategoryETF:       {Rate: 0.35, Max: 1600},
+				TaxCategoryFundDist:  {Rate: 0.12, Max: 1300},
+				TaxCategoryFundAcc:   {Rate: 1.32, Max: 4000, SellOnly: true},
+				TaxCategoryTOBExempt: {},
+			},
+			ReyndersRate:      30,
+			ReyndersThreshold: 10,
+		},
+	}
+
+	return &c
+}
+
+// DefaultConfigFile returns the configuration file in the XDG config directory
+func DefaultConfigFile() (string, error) {
+	if d := os.Getenv("XDG_CONFIG_HOME"); d != "" {
+		return filepath.Join(d, "fintrk", "config.yaml"), nil
+	}
+
+	d, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(d, ".config", "fintrk", "config.yaml"), nil
+}
+
+// LoadConfig reads the configuration file on top of the defaults; a missing
+// file is only an error when it was explicitly requested
+func LoadConfig(file string) (*Config, error) {
+	c := DefaultConfig()
+	explicit := file != ""
+
+	if !explicit {
+		f, err := DefaultConfigFile()
+		if err != nil {
+			return nil, err
+		}
+
+		file = f
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return c, c.ApplyEnv()
+		}
+
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, c); err != nil {
+		return nil, err
+	}
+
+	return c, c.ApplyEnv()
+}
+
+// ApplyEnv overrides settings with the FINTRK_* environment variables
+func (c *Config) ApplyEnv() error {
+	vars := map[string]*string{
+		"FINTRK_DBFILE":         &c.DBFile,
+		"FINTRK_DEFAULT_SOURCE": &c.DefaultSource,
+		"FINTRK_BASE_CURRENCY":  &c.BaseCurrency,
+		"FINTRK_LOCALE":         &c.Locale,
+		"FINTRK_FORMAT":         &c.Format,
+		"FINTRK_PRICE_FIELD":    &c.PriceField,
+		"FINTRK_CACHE_DIR":      &c.CacheDir,
+		"FINTRK_SMTP_HOST":      &c.SMTP.Host,
+		"FINTRK_SMTP_USERNAME":  &c.SMTP.Username,
+		"FINTRK_SMTP_PASSWORD":  &c.SMTP.Password,
+		"FINTRK_SMTP_FROM":      &c.SMTP.From,
+	}
+
+	for env, s := range vars {
+		if v := os.Getenv(env); v != "" {
+			*s = v
+		}
+	}
+
+	if v := os.Getenv("FINTRK_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+
+		c.Concurrency = n
+	}
+
+	if err := ValidatePriceField(c.PriceField); err != nil {
+		return err
+	}
+
+	if err := ValidateLotMethod(c.LotMethod); err != nil {
+		return err
+	}
+
+	if c.DBFile == "" {
+		d, err := homedir.Dir()
+		if err != nil {
+			return err
+		}
+
+		c.DBFile = filepath.Join(d, ".fintrk.db")
+	}
+
+	if c.CacheDir == "" {
+		d, err := DefaultCacheDir()
+		if err != nil {
+			return err
+		}
+
+		c.CacheDir = d
+	}
+
+	return nil
+}
+
+// DefaultCacheDir returns the HTTP cache directory in the XDG cache directory
+func DefaultCacheDir() (string, error) {
+	if d := os.Getenv("XDG_CACHE_HOME"); d != "" {
+		return filepath.Join(d, "fintrk"), nil
+	}
+
+	d, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(d, ".cache", "fintrk"), nil
+}
+
+// SourceHTTP returns the HTTP settings for a source, falling back to the
+// global settings for everything the source does not set
+func (c *Config) SourceHTTP(source string) HTTPConfig {
+	h := c.HTTP
+	s := c.Sources[source].HTTP
+
+	if s.Timeout != 0 {
+		h.Timeout = s.Timeout
+	}
+
+	if s.RetryMax != 0 {
+		h.RetryMax = s.RetryMax
+	}
+
+	if s.RetryWaitMin != 0 {
+		h.RetryWaitMin = s.RetryWaitMin
+	}
+
+	if s.RetryWaitMax != 0 {
+		h.RetryWaitMax = s.RetryWaitMax
+	}
+
+	if s.RateLimit != 0 {
+		h.RateLimit = s.RateLimit
+	}
+
+	if s.CacheTTL != 0 {
+		h.CacheTTL = s.CacheTTL
+	}
+
+	if s.MetaCacheTTL != 0 {
+		h.MetaCacheTTL = s.MetaCacheTTL
+	}
+
+	return h
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/olekukonko/tablewriter"
+)
+
+// PriceConflict flags a date on which two sources disagree on the price of
+// an ISIN by more than the tolerance
+type PriceConflict struct {
+	ID            string `storm:"id"`
+	ISIN          string `storm:"index"`
+	Date          time.Time
+	Source        string
+	Value         float64
+	OtherSource   string
+	OtherValue    float64
+	DiffPercent   float64
+	FirstDetected time.Time
+}
+
+// CheckConflict compares a stored valuation with a new one for the same date
+// from another source, and stores a conflict when they differ too much
+func (db *DB) CheckConflict(isin *ISIN, stored *Valuation, other *Valuation) error {
+	if stored.Source == "" || other.Source == "" || stored.Source == other.Source {
+		return nil
+	}
+
+	value := db.ValueOf(isin, stored)
+	otherValue := db.ValueOf(isin, other)
+
+	if value == 0 {
+		return nil
+	}
+
+	diff := math.Abs(otherValue-value) / value * 100
+	if diff <= db.config.Tolerance {
+		return nil
+	}
+
+	c := PriceConflict{
+		ID:            stored.ID + "@" + other.Source,
+		ISIN:          isin.ID,
+		Date:          stored.Date,
+		Source:        stored.Source,
+		Value:         value,
+		OtherSource:   other.Source,
+		OtherValue:    otherValue,
+		DiffPercent:   diff,
+		FirstDetected: time.Now(),
+	}
+
+	var existing PriceConflict
+
+	err := db.DB().One("ID", c.ID, &existing)
+	if err == nil {
+		c.FirstDetected = existing.FirstDetected
+	} else if !errors.Is(err, storm.ErrNotFound) {
+		return err
+	} else {
+		db.logger.Warnf(
+			"Sources disagree on '%s' at %s: %s says %.4f, %s says %.4f (%.2f%%)",
+			isin.ID, timeToDate(&c.Date), c.Source, c.Value, c.OtherSource, c.OtherValue, c.DiffPercent,
+		)
+	}
+
+	return db.DB().Save(&c)
+}
+
+func (db *DB) GetAllConflicts() ([]PriceConflict, error) {
+	var conflicts []PriceConflict
+
+	err := db.DB().All(&conflicts)
+
+	return conflicts, err
+}
+
+func (a *App) ShowConflicts(tableFormat string) error {
+	conflicts, err := a.DB().GetAllConflicts()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].ID < conflicts[j].ID
+	})
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ISIN", "Date", "Source", "Value", "Other source", "Other value", "Difference"})
+	configureRenderer(table, tableFormat)
+
+	for _, c := range conflicts {
+		table.Append([]string{
+			c.ISIN, timeToDate(&c.Date), c.Source, fmt.Sprintf("%.4f", c.Value), c.OtherSource, fmt.Sprintf("%.4f", c.OtherValue),
+			fmt.Sprintf("%.2f%%", c.DiffPercent),
+		})
+	}
+
+	table.Render()
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCrossCheckRecordsConflicts(t *testing.T) {
+	db := newReplayDB(t, "testdata/conflict")
+	isin := &ISIN{ID: "IE00B4L5Y983"}
+	isin.SetSourceChain([]string{DataSourceFT, DataSourceInvesting})
+	isin.SetSourceID(DataSourceInvesting, "995447")
+
+	if err := db.UpdateFromHTTP(context.Background(), isin); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts, err := db.GetAllConflicts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conflicts) != 1 {
+		t.Fatalf("got %d conflicts, want 1: %+v", len(conflicts), conflicts)
+	}
+
+	c := conflicts[0]
+	want := time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	if !c.Date.Equal(want) || c.Source != DataSourceFT || c.OtherSource != DataSourceInvesting || c.Value != 88.52 || c.OtherValue != 91.10 {
+		t.Errorf("conflict is %+v, want FT 88.52 against investing 91.10 at %s", c, timeToDate(&want))
+	}
+
+	// The other source only checks, it does not add prices
+	for _, v := range storedValuations(t, db, isin.ID) {
+		if v.Source != DataSourceFT {
+			t.Errorf("stored valuation %+v from another source", v)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/google/uuid"
+)
+
+const (
+	CorporateActionSplit  = "split"
+	CorporateActionMerger = "merger"
+)
+
+var (
+	ErrInvalidRatio           = errors.New("ratio must be positive")
+	ErrCorporateActionUnknown = errors.New("unknown corporate action")
+	ErrMergerIntoItself       = errors.New("an ISIN can not merge into itself (use a split)")
+
+	// endOfTime is later than any transaction
+	endOfTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// CorporateAction rescales the history of an ISIN from its date on. A split
+// turns every share into Ratio shares; a merger converts every share into
+// Ratio shares of NewISIN. Shares and prices are always expressed in the
+// units after the last split, unless the source already adjusted its prices
+type CorporateAction struct {
+	UUID           uuid.UUID `storm:"id"`
+	ISIN           string    `storm:"index"`
+	Type           string
+	Date           time.Time
+	Ratio          float64
+	NewISIN        string `storm:"index"`
+	PricesAdjusted bool
+}
+
+func (c *CorporateAction) String() string {
+	if c.Type == CorporateActionMerger {
+		return fmt.Sprintf("%s: merger of '%s' into '%s' at %g shares per share", timeToDate(&c.Date), c.ISIN, c.NewISIN, c.Ratio)
+	}
+
+	return fmt.Sprintf("%s: split of '%s' at %g shares per share", timeToDate(&c.Date), c.ISIN, c.Ratio)
+}
+
+// CreateCorporateAction stores the action and recalculates the shares and
+// value of the ISINs involved
+func (db *DB) CreateCorporateAction(c *CorporateAction) error {
+	if c.Ratio <= 0 {
+		return fmt.Errorf("%w: %g", ErrInvalidRatio, c.Ratio)
+	}
+
+	// The shares would be counted both before and after the merger
+	if c.Type == CorporateActionMerger && c.NewISIN == c.ISIN {
+		return fmt.Errorf("%w: '%s'", ErrMergerIntoItself, c.ISIN)
+	}
+
+	for _, id := range []string{c.ISIN, c.NewISIN} {
+		if id == "" {
+			continue
+		}
+
+		if _, err := db.GetISIN(id); err != nil {
+			return fmt.Errorf("%w: '%s'", err, id)
+		}
+	}
+
+	c.UUID = uuid.New()
+
+	if err := db.DB().Save(c); err != nil {
+		return err
+	}
+
+	return db.refreshCorporateAction(c)
+}
+
+func (db *DB) GetAllCorporateActions() ([]CorporateAction, error) {
+	var actions []CorporateAction
+
+	err := db.DB().All(&actions)
+
+	return actions, err
+}
+
+func (db *DB) DeleteCorporateAction(id string) error {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	var c CorporateAction
+
+	if err := db.DB().One("UUID", u, &c); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return fmt.Errorf("%w: '%s'", ErrCorporateActionUnknown, id)
+		}
+
+		return err
+	}
+
+	if err := db.DB().DeleteStruct(&c); err != nil {
+		return err
+	}
+
+	return db.refreshCorporateAction(&c)
+}
+
+func (db *DB) refreshCorporateAction(c *CorporateAction) error {
+	for _, id := range []string{c.ISIN, c.NewISIN} {
+		if id == "" {
+			continue
+		}
+
+		if err := db.UpdateShares(id); err != nil {
+			return err
+		}
+
+		isin, err := db.GetISIN(id)
+		if err != nil {
+			return err
+		}
+
+		if err := db.RefreshValuePerShare(isin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// corporateActions returns the actions of the ISIN (or those merging into it)
+// sorted by date
+func (db *DB) corporateActions(field string, isin string) ([]CorporateAction, error) {
+	var actions []CorporateAction
+
+	err := db.DB().Select(q.Eq(field, isin)).Find(&actions)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Date.Before(actions[j].Date)
+	})
+
+	return actions, nil
+}
+
+// mergedAway returns the date of the merger of the ISIN into another one, when
+// that happened up to the date
+func mergedAway(actions []CorporateAction, until time.Time) (time.Time, bool) {
+	for _, c := range actions {
+		if c.Type == CorporateActionMerger && !c.Date.After(until) {
+			return c.Date, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// mergersInto returns the mergers of other ISINs into the ISIN up to the
+// date, sorted by date
+func (db *DB) mergersInto(isin string, until time.Time) ([]CorporateAction, error) {
+	actions, err := db.corporateActions("NewISIN", isin)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []CorporateAction
+
+	for _, c := range actions {
+		if c.Type == CorporateActionMerger && !c.Date.After(until) {
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
+
+// splitFactor returns the number of current shares per share held at the date
+func splitFactor(actions []CorporateAction, d time.Time) float64 {
+	f := 1.0
+
+	for _, c := range actions {
+		if c.Type == CorporateActionSplit && d.Before(c.Date) {
+			f *= c.Ratio
+		}
+	}
+
+	return f
+}
+
+// priceFactor is like splitFactor, but ignores the splits the source already
+// adjusted its prices for
+func priceFactor(actions []CorporateAction, d time.Time) float64 {
+	f := 1.0
+
+	for _, c := range actions {
+		if c.Type == CorporateActionSplit && !c.PricesAdjusted && d.Before(c.Date) {
+			f *= c.Ratio
+		}
+	}
+
+	return f
+}
+
+// adjustValuation rescales the valuation to the current units of the ISIN
+func (db *DB) adjustValuation(v *Valuation) error {
+	actions, err := db.corporateActions("ISIN", v.ISIN)
+	if err != nil {
+		return err
+	}
+
+	f := priceFactor(actions, v.Date)
+	if f == 1 {
+		return nil
+	}
+
+	v.Open /= f
+	v.High /= f
+	v.Low /= f
+	v.Close /= f
+
+	return nil
+}
+
+// sharesAt sums the transactions of the ISIN up to the date in current units;
+// shares merged away are moved to the new ISIN on the date of the merger
+func (db *DB) sharesAt(isin string, transactions []Transaction, d time.Time) (float64, error) {
+	actions, err := db.corporateActions("ISIN", isin)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, c := range actions {
+		if c.Type == CorporateActionMerger && !d.Before(c.Date) {
+			return 0, nil
+		}
+	}
+
+	var v float64
+
+	for _, tx := range transactions {
+		if tx.Date.After(d) {
+			continue
+		}
+
+		v += tx.TotalShares * splitFactor(actions, tx.Date)
+	}
+
+	mergers, err := db.corporateActions("NewISIN", isin)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range mergers {
+		if m.Type != CorporateActionMerger || d.Before(m.Date) {
+			continue
+		}
+
+		old, err := db.GetSharesAt(m.ISIN, m.Date.Add(-time.Nanosecond))
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return 0, err
+		}
+
+		v += old * m.Ratio * splitFactor(actions, m.Date)
+	}
+
+	return v, nil
+}
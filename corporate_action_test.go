@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMergerCarriesLots(t *testing.T) {
+	db := newTestDB(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	for _, id := range []string{"BE0000000001", "BE0000000002"} {
+		if err := db.DB().Save(&ISIN{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	for _, tx := range []Transaction{
+		{ISIN: "BE0000000001", Date: day(2020, 1, 10), TotalShares: 10, TotalValue: 1000, Fee: 10},
+		{ISIN: "BE0000000001", Date: day(2020, 6, 10), TotalShares: 10, TotalValue: 1500},
+		{ISIN: "BE0000000002", Date: day(2021, 6, 1), TotalShares: 5, TotalValue: 600},
+		{ISIN: "BE0000000002", Date: day(2023, 1, 2), TotalShares: -15, TotalValue: -3000},
+	} {
+		tx := tx
+		tx.Type = TransactionTypeTrade
+
+		if err := db.CreateTransaction(&tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []CorporateAction{
+		{ISIN: "BE0000000001", Type: CorporateActionSplit, Date: day(2021, 1, 1), Ratio: 2},
+		{ISIN: "BE0000000001", Type: CorporateActionMerger, Date: day(2022, 1, 3), Ratio: 0.5, NewISIN: "BE0000000002"},
+	} {
+		c := c
+
+		if err := db.CreateCorporateAction(&c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	disposals, lots, err := db.MatchLots("BE0000000002", LotMethodFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantDisposals := []Disposal{
+		{Acquired: day(2020, 1, 10), Shares: 10, Proceeds: 2000, Cost: 1010},
+		{Acquired: day(2020, 6, 10), Shares: 5, Proceeds: 1000, Cost: 750},
+	}
+
+	if len(disposals) != len(wantDisposals) {
+		t.Fatalf("got %d disposals, want %d: %+v", len(disposals), len(wantDisposals), disposals)
+	}
+
+	for k, w := range wantDisposals {
+		d := disposals[k]
+
+		if !d.Acquired.Equal(w.Acquired) || !near(d.Shares, w.Shares) || !near(d.Proceeds, w.Proceeds) || !near(d.Cost, w.Cost) {
+			t.Errorf("disposal %d is %+v, want %+v", k, d, w)
+		}
+	}
+
+	wantLots := []Lot{
+		{ISIN: "BE0000000002", Acquired: day(2020, 6, 10), Shares: 5, Cost: 750},
+		{ISIN: "BE0000000002", Acquired: day(2021, 6, 1), Shares: 5, Cost: 600},
+	}
+
+	if len(lots) != len(wantLots) {
+		t.Fatalf("got %d lots, want %d: %+v", len(lots), len(wantLots), lots)
+	}
+
+	for k, w := range wantLots {
+		l := lots[k]
+
+		if l.ISIN != w.ISIN || !l.Acquired.Equal(w.Acquired) || !near(l.Shares, w.Shares) || !near(l.Cost, w.Cost) {
+			t.Errorf("lot %d is %+v, want %+v", k, l, w)
+		}
+	}
+
+	// 25 shares cost 3100 after the merger, 10 of them are left
+	if cost, err := db.CostBasis("BE0000000002"); err != nil || !near(cost, 1240) {
+		t.Errorf("cost basis of the new ISIN is %g (%v), want 1240", cost, err)
+	}
+
+	if cost, err := db.CostBasis("BE0000000001"); err != nil || cost != 0 {
+		t.Errorf("cost basis of the old ISIN is %g (%v), want 0", cost, err)
+	}
+
+	if _, lots, err := db.MatchLots("BE0000000001", LotMethodFIFO); err != nil || len(lots) != 0 {
+		t.Errorf("the old ISIN still has lots %+v (%v)", lots, err)
+	}
+
+	if shares, err := db.GetSharesAt("BE0000000002", day(2024, 1, 1)); err != nil || !near(shares, 10) {
+		t.Errorf("new ISIN holds %g shares (%v), want 10", shares, err)
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
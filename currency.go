@@ -12,10 +12,16 @@ type Currency struct {
 	printer *message.Printer
 }
 
-func (c *Currency) Initialize() error {
-	l, err := jibber_jabber.DetectLanguage()
-	if err != nil {
-		return err
+// Initialize sets up the printer for the locale, or for the locale of the
+// environment when empty
+func (c *Currency) Initialize(l string) error {
+	if l == "" {
+		detected, err := jibber_jabber.DetectLanguage()
+		if err != nil {
+			return err
+		}
+
+		l = detected
 	}
 
 	lang, err := language.Parse(l)
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// RunDaemon keeps the database open and updates all tracked funds according
+// to the schedule, until it receives SIGINT or SIGTERM; ISINs already being
+// updated are finished before returning, the others are skipped
+func (a *App) RunDaemon(s *Schedule, runNow bool, metricsListen string) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+
+	defer signal.Stop(sig)
+
+	if metricsListen != "" {
+		go a.serveDaemonMetrics(metricsListen)
+	}
+
+	for {
+		if !runNow {
+			next := s.Next(time.Now())
+			a.Logger().Infof("Next update at %s", next)
+
+			timer := time.NewTimer(time.Until(next))
+
+			select {
+			case received := <-sig:
+				timer.Stop()
+				a.Logger().Infof("Received %s, stopping", received)
+
+				return nil
+			case <-timer.C:
+			}
+		}
+
+		runNow = false
+
+		if stop := a.runDaemonUpdate(sig); stop {
+			return nil
+		}
+	}
+}
+
+// runDaemonUpdate performs one update run; it returns true when a signal was
+// received while the run was in progress
+func (a *App) runDaemonUpdate(sig <-chan os.Signal) bool {
+	done := make(chan error, 1)
+	stop := false
+
+	a.Logger().Info("Starting scheduled update")
+
+	// Not derived from the command's context: a signal should let the running
+	// fetches finish instead of cancelling them, but not start new ones
+	ctx, cancel := a.UpdateContext(context.Background())
+	defer cancel()
+
+	start, stopStarting := context.WithCancel(context.Background())
+	defer stopStarting()
+
+	go func() {
+		results, err := a.DB().UpdateValuationsUntil(ctx, start)
+		if err == nil {
+			for _, r := range results {
+				if r.Err != nil && !errors.Is(r.Err, ErrUpdateSkipped) {
+					a.Logger().Warnf("Failed to update '%s': %v", r.ISIN, r.Err)
+				}
+			}
+
+			if skipped := results.Skipped(); skipped > 0 {
+				a.Logger().Infof("Skipped %d ISINs because of the stop signal", skipped)
+			}
+		}
+
+		done <- err
+	}()
+
+	for {
+		select {
+		case received := <-sig:
+			a.Logger().Infof("Received %s, finishing running fetches before stopping", received)
+
+			stopStarting()
+
+			stop = true
+		case err := <-done:
+			if err != nil {
+				a.Logger().Errorf("Scheduled update failed: %v", err)
+			}
+
+			return stop
+		}
+	}
+}
+
+func (a *App) serveDaemonMetrics(listen string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", a.MetricsHandler(
+		func() (*DB, error) { return a.DB(), nil },
+		func(*DB) {},
+	))
+
+	a.Logger().Infof("Serving metrics on %s/metrics", listen)
+
+	if err := http.ListenAndServe(listen, mux); err != nil { //nolint:gosec
+		a.Logger().Errorf("Error serving metrics: %v", err)
+	}
+}
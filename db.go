@@ -1,9 +1,12 @@
 package main
 
 import (
+	"sync"
+	"time"
+
 	"github.com/asdine/storm/v3"
-	"github.com/remeh/sizedwaitgroup"
 	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
 )
 
 type DB struct {
@@ -11,12 +14,22 @@ type DB struct {
 
 	file   string
 	logger *logrus.Logger
+	config *Config
+	now    func() time.Time
+
+	statsLock    sync.Mutex
+	alertsLock   sync.Mutex
+	alerts       []*Alert
+	limitersLock sync.Mutex
+	limiters     map[string]*rateLimiter
 }
 
-func NewDB(file string, logger *logrus.Logger) *DB {
+func NewDB(config *Config, logger *logrus.Logger) *DB {
 	db := DB{
-		file:   file,
+		file:   config.DBFile,
 		logger: logger,
+		config: config,
+		now:    time.Now,
 	}
 
 	return &db
@@ -26,13 +39,31 @@ func (db *DB) DB() *storm.DB {
 	return db.db
 }
 
+// Now returns the current time, which is pinned when replaying HTTP fixtures
+func (db *DB) Now() time.Time {
+	return db.now()
+}
+
+// Today returns the current date at midnight UTC, like the dates parsed from
+// the command line
+func (db *DB) Today() time.Time {
+	y, m, d := db.Now().Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// PinClock makes Now always return t
+func (db *DB) PinClock(t time.Time) {
+	db.now = func() time.Time { return t }
+}
+
 func (db *DB) Initialize() error {
 	myDB, err := storm.Open(db.file)
 	if err != nil {
 		return err
 	}
 
-	dbBacked := []interface{}{Valuation{}, ISIN{}, Transaction{}}
+	dbBacked := []interface{}{Valuation{}, ISIN{}, Transaction{}, SourceStats{}, AlertRule{}, PriceConflict{}, QuarantinedValuation{}, CorporateAction{}, CashAccount{}, CashMovement{}, Asset{}}
 
 	for _, e := range dbBacked {
 		if err := myDB.Init(e); err != nil {
@@ -42,35 +73,27 @@ func (db *DB) Initialize() error {
 
 	db.db = myDB
 
-	return nil
+	return db.migrateXIDs()
 }
 
-func (db *DB) Close() {
-	db.DB().Close()
-}
-
-func (db *DB) UpdateValuationsAll() error {
-	isins, err := db.GetAllISIN()
+// InitializeReadOnly opens the database with a shared lock: other readers can
+// open it at the same time, but a writer cannot, and opening fails after the
+// timeout while a writer holds the file. Close it between two reads, so the
+// CLI can write in between
+func (db *DB) InitializeReadOnly() error {
+	myDB, err := storm.Open(db.file, storm.BoltOptions(0o600, &bolt.Options{
+		ReadOnly: true,
+		Timeout:  5 * time.Second,
+	}))
 	if err != nil {
 		return err
 	}
 
-	swg := sizedwaitgroup.New(8)
-
-	for isin := range isins {
-		swg.Add()
-
-		go func(i *ISIN) {
-			defer swg.Done()
-			db.logger.Infof("Updating ISIN: %s", i.ID)
-
-			if err := db.UpdateFromHTTP(i); err != nil {
-				db.logger.Errorf("Error updating ISIN '%s': %v", i.ID, err)
-			}
-		}(&isins[isin])
-	}
-
-	swg.Wait()
+	db.db = myDB
 
 	return nil
 }
+
+func (db *DB) Close() {
+	db.DB().Close()
+}
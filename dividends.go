@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/asdine/storm/v3"
+	"github.com/olekukonko/tablewriter"
+)
+
+var ErrNoBaseCurrency = errors.New("no base currency configured")
+
+// DividendTotals adds up dividend payouts; Base is the net amount in the
+// base currency, converted at the date of each payout
+type DividendTotals struct {
+	Gross float64
+	Tax   float64
+	Net   float64
+	Base  float64
+}
+
+func (d *DividendTotals) add(t *Transaction, base float64) {
+	d.Gross += t.TotalValue
+	d.Tax += t.Tax
+	d.Net += t.Net()
+	d.Base += base
+}
+
+type dividendKey struct {
+	Group      string
+	ISIN       string
+	Nomination string
+}
+
+// ShowDividends prints the payouts per ISIN and year, the yields and the
+// income per month; with base, the net amounts are also shown in the base
+// currency. A year other than 0 limits the payouts to that year
+func (a *App) ShowDividends(tableFormat string, base bool, year int) error {
+	if base && a.config.BaseCurrency == "" {
+		return ErrNoBaseCurrency
+	}
+
+	dividends, err := a.DB().GetDividends()
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	isins, err := a.DB().GetAllISIN()
+	if err != nil {
+		return err
+	}
+
+	byID := map[string]*ISIN{}
+	for k := range isins {
+		byID[isins[k].ID] = &isins[k]
+	}
+
+	perYear := map[dividendKey]*DividendTotals{}
+	perMonth := map[dividendKey]*DividendTotals{}
+	ttm := map[string]*DividendTotals{}
+	ttmStart := a.DB().Now().AddDate(-1, 0, 0)
+
+	for k := range dividends {
+		t := &dividends[k]
+
+		i, ok := byID[t.ISIN]
+		if !ok {
+			continue
+		}
+
+		var converted float64
+
+		if base {
+			converted, err = a.DB().Convert(t.Net(), i.Nomination, a.config.BaseCurrency, t.Date)
+			if err != nil {
+				return fmt.Errorf("%w (run 'fx update' or 'fx set')", err)
+			}
+		}
+
+		if t.Date.After(ttmStart) {
+			if ttm[t.ISIN] == nil {
+				ttm[t.ISIN] = &DividendTotals{}
+			}
+
+			ttm[t.ISIN].add(t, converted)
+		}
+
+		if year != 0 && t.Date.Year() != year {
+			continue
+		}
+
+		y := dividendKey{strconv.Itoa(t.Date.Year()), t.ISIN, i.Nomination}
+		m := dividendKey{t.Date.Format("2006-01"), "", i.Nomination}
+
+		for key, totals := range map[dividendKey]map[dividendKey]*DividendTotals{y: perYear, m: perMonth} {
+			if totals[key] == nil {
+				totals[key] = &DividendTotals{}
+			}
+
+			totals[key].add(t, converted)
+		}
+	}
+
+	a.showDividendTable(tableFormat, base, byID, perYear, []string{"ISIN", "Name", "Year"})
+	a.showDividendTable(tableFormat, base, byID, perMonth, []string{"Month"})
+
+	return a.showYieldTable(tableFormat, byID, ttm)
+}
+
+func (a *App) showDividendTable(tableFormat string, base bool, byID map[string]*ISIN, totals map[dividendKey]*DividendTotals, header []string) {
+	keys := make([]dividendKey, 0, len(totals))
+	for k := range totals {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ISIN != keys[j].ISIN {
+			return keys[i].ISIN < keys[j].ISIN
+		}
+
+		if keys[i].Group != keys[j].Group {
+			return keys[i].Group < keys[j].Group
+		}
+
+		return keys[i].Nomination < keys[j].Nomination
+	})
+
+	header = append(header, "Nom", "Gross", "Tax", "Net")
+	if base {
+		header = append(header, "Net ("+a.config.BaseCurrency+")")
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	configureRenderer(table, tableFormat)
+
+	for _, k := range keys {
+		t := totals[k]
+
+		var row []string
+
+		if k.ISIN != "" {
+			row = append(row, k.ISIN, byID[k.ISIN].Name)
+		}
+
+		row = append(row, k.Group, k.Nomination, a.localize(k.Nomination, t.Gross), a.localize(k.Nomination, t.Tax), a.localize(k.Nomination, t.Net))
+
+		if base {
+			row = append(row, a.localize(a.config.BaseCurrency, t.Base))
+		}
+
+		table.Append(row)
+	}
+
+	table.Render()
+}
+
+func (a *App) showYieldTable(tableFormat string, byID map[string]*ISIN, ttm map[string]*DividendTotals) error {
+	ids := make([]string, 0, len(ttm))
+	for id := range ttm {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ISIN", "Name", "Nom", "Gross (12m)", "Value", "Yield", "Cost", "Yield on cost"})
+	configureRenderer(table, tableFormat)
+
+	for _, id := range ids {
+		i := byID[id]
+		gross := ttm[id].Gross
+
+		cost, err := a.DB().CostBasis(id)
+		if err != nil {
+			return err
+		}
+
+		table.Append([]string{
+			id, i.Name, i.Nomination, a.localize(i.Nomination, gross),
+			a.localize(i.Nomination, i.OwnedValue()), percentOf(gross, i.OwnedValue()),
+			a.localize(i.Nomination, cost), percentOf(gross, cost),
+		})
+	}
+
+	table.Render()
+
+	return nil
+}
+
+// localize formats the value in the currency, logging failures
+func (a *App) localize(nomination string, value float64) string {
+	s, err := a.currency.Localize(nomination, value)
+	if err != nil {
+		a.Logger().Error(err)
+	}
+
+	return s
+}
+
+func percentOf(part, total float64) string {
+	if total == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%.2f%%", part/total*100)
+}
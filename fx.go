@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// DataSourceECB provides the euro reference rates of the European
+	// Central Bank
+	DataSourceECB = "ecb"
+
+	fxPrefix = "FX:"
+	fxPivot  = "EUR"
+
+	ecbURL        = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+	ecbHistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+)
+
+var ErrNoFXRate = errors.New("no exchange rate")
+
+// minorCurrencies are quoted in a fraction of another currency
+var minorCurrencies = map[string]struct {
+	Currency string
+	Factor   float64
+}{
+	"GBX": {"GBP", 0.01},
+	"GBp": {"GBP", 0.01},
+	"ZAc": {"ZAR", 0.01},
+	"ILA": {"ILS", 0.01},
+}
+
+type ecbEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+// fxID is the pseudo ISIN under which the rates from one currency to another
+// are stored as valuations: the price of one unit of from, in to
+func fxID(from, to string) string {
+	return fxPrefix + from + to
+}
+
+func normalizeCurrency(c string) (string, float64) {
+	if m, ok := minorCurrencies[c]; ok {
+		return m.Currency, m.Factor
+	}
+
+	return strings.ToUpper(c), 1
+}
+
+// FXRate returns the amount of to for one unit of from at the date, using
+// the last known rate; rates are looked up directly, inverted, or crossed
+// through the euro
+func (db *DB) FXRate(from, to string, d time.Time) (float64, error) {
+	from, fromFactor := normalizeCurrency(from)
+	to, toFactor := normalizeCurrency(to)
+
+	if from == to {
+		return fromFactor / toFactor, nil
+	}
+
+	rate, err := db.fxDirect(from, to, d)
+	if errors.Is(err, storm.ErrNotFound) {
+		var r1, r2 float64
+
+		r1, err = db.fxDirect(from, fxPivot, d)
+		if err == nil {
+			r2, err = db.fxDirect(fxPivot, to, d)
+			rate = r1 * r2
+		}
+	}
+
+	if errors.Is(err, storm.ErrNotFound) {
+		return 0, fmt.Errorf("%w: '%s' to '%s' at %s", ErrNoFXRate, from, to, timeToDate(&d))
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return rate * fromFactor / toFactor, nil
+}
+
+func (db *DB) fxDirect(from, to string, d time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	v, err := db.GetValuationAt(fxID(from, to), d)
+	if err == nil && v.Close != 0 {
+		return v.Close, nil
+	}
+
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return 0, err
+	}
+
+	v, err = db.GetValuationAt(fxID(to, from), d)
+	if err != nil {
+		return 0, err
+	}
+
+	if v.Close == 0 {
+		return 0, storm.ErrNotFound
+	}
+
+	return 1 / v.Close, nil
+}
+
+// Convert returns the amount in from as an amount in to
+func (db *DB) Convert(amount float64, from, to string, d time.Time) (float64, error) {
+	rate, err := db.FXRate(from, to, d)
+	if err != nil {
+		return 0, err
+	}
+
+	return amount * rate, nil
+}
+
+func fxValuation(from, to string, d time.Time, rate float64, source string) *Valuation {
+	v := Valuation{
+		ISIN:   fxID(from, to),
+		Date:   d,
+		Open:   rate,
+		High:   rate,
+		Low:    rate,
+		Close:  rate,
+		Source: source,
+	}
+
+	v.UpdateID()
+
+	return &v
+}
+
+// SetFXRate stores the amount of to for one unit of from at the date
+func (db *DB) SetFXRate(from, to string, d time.Time, rate float64) error {
+	return db.DB().Save(fxValuation(strings.ToUpper(from), strings.ToUpper(to), d, rate, DataSourceManual))
+}
+
+// UpdateFXFromECB stores the reference rates of the last 90 days, or all of
+// them since 1999, and returns the number of rates
+func (db *DB) UpdateFXFromECB(ctx context.Context, history bool) (int, error) {
+	u := ecbURL
+	if history {
+		u = ecbHistoryURL
+	}
+
+	req, err := retryablehttp.NewRequest("GET", u, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := doRequest(ctx, db.NewHTTPClient(DataSourceECB), req)
+	if err != nil {
+		return 0, err
+	}
+
+	var env ecbEnvelope
+
+	if err := xml.Unmarshal(body, &env); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.DB().Begin(true)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	count := 0
+
+	for _, day := range env.Cube.Days {
+		d, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, r := range day.Rates {
+			if err := tx.Save(fxValuation(fxPivot, r.Currency, d, r.Rate, DataSourceECB)); err != nil {
+				return 0, err
+			}
+
+			count++
+		}
+	}
+
+	return count, tx.Commit()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+var ErrHTTPStatus = errors.New("unexpected HTTP status")
+
+// NewHTTPClient returns a client configured with the HTTP settings of the
+// source; when recording or replaying, its responses go through the fixtures
+// and bypass the cache
+func (db *DB) NewHTTPClient(source string) *retryablehttp.Client {
+	h := db.config.SourceHTTP(source)
+	client := newHTTPClient(h, db.logger)
+
+	if db.config.HTTPReplayDir != "" {
+		client.HTTPClient.Transport = &ReplayTransport{Dir: db.config.HTTPReplayDir}
+		client.RetryMax = 0
+
+		return client
+	}
+
+	if h.RateLimit > 0 {
+		client.HTTPClient.Transport = &RateLimitTransport{
+			Limiter: db.limiter(source, h.RateLimit),
+			Base:    client.HTTPClient.Transport,
+		}
+	}
+
+	if db.config.HTTPRecordDir != "" {
+		client.HTTPClient.Transport = &ReplayTransport{
+			Dir:    db.config.HTTPRecordDir,
+			Record: true,
+			Base:   client.HTTPClient.Transport,
+		}
+
+		return client
+	}
+
+	if db.config.CacheDir != "" && (h.CacheTTL > 0 || h.MetaCacheTTL > 0) {
+		client.HTTPClient.Transport = &CacheTransport{
+			Dir:     filepath.Join(db.config.CacheDir, "http", source),
+			TTL:     h.CacheTTL,
+			MetaTTL: h.MetaCacheTTL,
+			Base:    client.HTTPClient.Transport,
+			Logger:  db.logger,
+		}
+	}
+
+	return client
+}
+
+func newHTTPClient(h HTTPConfig, logger interface{}) *retryablehttp.Client {
+	client := retryablehttp.NewClient()
+	client.Logger = logger
+	client.HTTPClient.Timeout = h.Timeout
+	client.RetryMax = h.RetryMax
+	client.RetryWaitMin = h.RetryWaitMin
+	client.RetryWaitMax = h.RetryWaitMax
+
+	return client
+}
+
+// doRequest sends the request and returns the body of the response, which
+// must have a 2xx status
+func doRequest(ctx context.Context, client *retryablehttp.Client, req *retryablehttp.Request) ([]byte, error) {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%w: %s %s: %s", ErrHTTPStatus, req.Method, req.URL, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type requestKind int
+
+const (
+	requestData requestKind = iota
+	requestMeta
+)
+
+type requestKindKey struct{}
+
+// withMetaRequest marks the requests sent with the context as metadata
+// lookups, which are cached with the metadata TTL
+func withMetaRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestKindKey{}, requestMeta)
+}
+
+func requestKindOf(req *http.Request) requestKind {
+	if k, ok := req.Context().Value(requestKindKey{}).(requestKind); ok {
+		return k
+	}
+
+	return requestData
+}
+
+// CacheTransport answers requests from an on-disk cache while the stored
+// response is younger than the TTL of the kind of request; only successful
+// responses are stored
+type CacheTransport struct {
+	Dir     string
+	TTL     time.Duration
+	MetaTTL time.Duration
+	Base    http.RoundTripper
+	Logger  *logrus.Logger
+}
+
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ttl := t.TTL
+	if requestKindOf(req) == requestMeta {
+		ttl = t.MetaTTL
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if ttl <= 0 {
+		return base.RoundTrip(req)
+	}
+
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(t.Dir, replayKey(req, reqBody)+".json")
+
+	if st, err := os.Stat(file); err == nil && time.Since(st.ModTime()) < ttl {
+		resp, err := readFixture(file, req)
+		if err == nil {
+			t.Logger.Debugf("Cache hit for %s %s", req.Method, req.URL)
+			return resp, nil
+		}
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, nil
+	}
+
+	if err := os.MkdirAll(t.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	if err := writeFixture(file, req, reqBody, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// rateLimiter spaces requests at least interval apart
+type rateLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Wait blocks until the next request may be sent, or the context is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.lock.Lock()
+
+	now := time.Now()
+	at := l.next
+
+	if at.Before(now) {
+		at = now
+	}
+
+	l.next = at.Add(l.interval)
+	l.lock.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RateLimitTransport sends requests through a limiter shared by all the
+// clients of a source, so retries and concurrent updates are throttled too
+type RateLimitTransport struct {
+	Limiter *rateLimiter
+	Base    http.RoundTripper
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(req)
+}
+
+// limiter returns the rate limiter of the source, creating it on first use
+func (db *DB) limiter(source string, perSecond float64) *rateLimiter {
+	db.limitersLock.Lock()
+	defer db.limitersLock.Unlock()
+
+	if db.limiters == nil {
+		db.limiters = map[string]*rateLimiter{}
+	}
+
+	l, ok := db.limiters[source]
+	if !ok {
+		l = &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+		db.limiters[source] = l
+	}
+
+	return l
+}
+
+// ClearHTTPCache removes all cached responses
+func ClearHTTPCache(dir string) error {
+	return os.RemoveAll(filepath.Join(dir, "http"))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const replayClockFile = "clock"
+
+var ErrNoFixture = errors.New("no recorded response")
+
+// ReplayTransport records HTTP responses into a fixtures directory, or
+// replays them from it without touching the network; requests are matched on
+// method, URL and body
+type ReplayTransport struct {
+	Dir    string
+	Record bool
+	Base   http.RoundTripper
+}
+
+// replayFixture is a recorded request and its response
+type replayFixture struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        string      `json:"body"`
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(t.Dir, replayKey(req, reqBody)+".json")
+
+	if t.Record {
+		return t.record(req, reqBody, file)
+	}
+
+	return t.replay(req, file)
+}
+
+func (t *ReplayTransport) record(req *http.Request, reqBody []byte, file string) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFixture(file, req, reqBody, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (t *ReplayTransport) replay(req *http.Request, file string) (*http.Response, error) {
+	resp, err := readFixture(file, req)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s %s (%s)", ErrNoFixture, req.Method, req.URL, file)
+		}
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// readRequestBody returns the body of the request and rewinds it, so it can
+// still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	return b, nil
+}
+
+// writeFixture stores the request and its response into the file; the body
+// of the response is rewound so the caller can still read it
+func writeFixture(file string, req *http.Request, reqBody []byte, resp *http.Response) error {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	f := replayFixture{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(reqBody),
+		Status:      resp.StatusCode,
+		Header:      resp.Header,
+		Body:        string(body),
+	}
+
+	j, err := json.MarshalIndent(&f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, j, 0o600)
+}
+
+// readFixture builds the response to the request from the file
+func readFixture(file string, req *http.Request) (*http.Response, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var f replayFixture
+
+	if err := json.Unmarshal(content, &f); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Header,
+		Body:          ioutil.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
+
+// replayKey names the fixture of a request after its host and a hash of the
+// method, URL and body
+func replayKey(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + "\n" + req.URL.String() + "\n"))
+	h.Write(body)
+
+	return req.URL.Hostname() + "-" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// StartRecording creates the fixtures directory and stores the current time,
+// so replaying builds the same (time dependent) requests; a directory that
+// already has a clock keeps it, so its earlier fixtures still match
+func StartRecording(dir string, now time.Time) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	file := filepath.Join(dir, replayClockFile)
+
+	if _, err := os.Stat(file); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return ioutil.WriteFile(file, []byte(now.Format(time.RFC3339)), 0o600)
+}
+
+// ReplayClock returns the time at which the fixtures were recorded
+func ReplayClock(dir string) (time.Time, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, replayClockFile))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(content)))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/sirupsen/logrus"
+)
+
+// newReplayDB opens an empty database that replays the HTTP fixtures in the
+// directory, at the time they were recorded
+func newReplayDB(t *testing.T, fixtures string) *DB {
+	t.Helper()
+
+	now, err := ReplayClock(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := DefaultConfig()
+	c.HTTPReplayDir = fixtures
+
+	return openTestDB(t, c, now)
+}
+
+// newTestDB opens an empty database without network access, with the clock
+// pinned to the date
+func newTestDB(t *testing.T, now time.Time) *DB {
+	t.Helper()
+
+	c := DefaultConfig()
+	c.HTTPReplayDir = t.TempDir()
+
+	return openTestDB(t, c, now)
+}
+
+func openTestDB(t *testing.T, c *Config, now time.Time) *DB {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	c.DBFile = filepath.Join(t.TempDir(), "fintrk.db")
+
+	db := NewDB(c, logger)
+	db.PinClock(now)
+
+	if err := db.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.DB().Close() })
+
+	return db
+}
+
+// storedValuations returns the valuations of the ISIN, oldest first
+func storedValuations(t *testing.T, db *DB, isin string) []Valuation {
+	t.Helper()
+
+	var vals []Valuation
+
+	if err := db.DB().Select(q.Eq("ISIN", isin)).OrderBy("Date").Find(&vals); err != nil {
+		t.Fatal(err)
+	}
+
+	return vals
+}
+
+func TestStartRecordingKeepsClock(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	first := time.Date(2024, 3, 15, 18, 0, 0, 0, time.UTC)
+
+	if err := StartRecording(dir, first); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StartRecording(dir, first.AddDate(0, 0, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReplayClock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Equal(first) {
+		t.Errorf("clock is %s, want %s", got, first)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+var (
+	ErrInvalidISIN      = errors.New("invalid ISIN")
+	ErrNotResolved      = errors.New("no ISIN found for identifier")
+	ErrAmbiguousListing = errors.New("identifier matches several listings, choose one with --pick")
+	ErrNoListing        = errors.New("no listing on the exchange and in the currency")
+
+	isinFormat    = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{9}[0-9]$`)
+	isinLabel     = regexp.MustCompile(`ISIN`)
+	isinCandidate = regexp.MustCompile(`\b[A-Z]{2}[A-Z0-9]{9}[0-9]\b`)
+)
+
+// isinWindow is how far after an 'ISIN' label a page is searched for the ISIN
+const isinWindow = 300
+
+// cryptoPrefix marks identifiers of cryptocurrencies, which have no ISIN
+const cryptoPrefix = "CRYPTO:"
+
+// Listing is a security as found by the search of a source; ISIN is empty
+// when the search does not return it, SourceID when the source does not need
+// one besides the ISIN. URL is the page of the listing at the source
+type Listing struct {
+	ISIN       string
+	SourceID   string
+	URL        string
+	Symbol     string
+	Name       string
+	Exchange   string
+	Currency   string
+	AssetClass string
+	Source     string
+}
+
+// LooksLikeISIN reports whether the identifier has the format of an ISIN,
+// regardless of its check digit
+func LooksLikeISIN(id string) bool {
+	return isinFormat.MatchString(id)
+}
+
+// IsCrypto reports whether the identifier is a cryptocurrency, like
+// CRYPTO:BTC
+func IsCrypto(id string) bool {
+	return strings.HasPrefix(id, cryptoPrefix)
+}
+
+// CryptoID returns the identifier of the cryptocurrency with the symbol
+func CryptoID(symbol string) string {
+	return cryptoPrefix + strings.ToUpper(symbol)
+}
+
+// formatShares formats the number of shares with the decimals, or with 8
+// decimals for cryptocurrencies, which are held in small fractions
+func formatShares(id string, shares float64, decimals int) string {
+	if IsCrypto(id) {
+		decimals = 8
+	}
+
+	return strconv.FormatFloat(shares, 'f', decimals, 64)
+}
+
+// ValidateISIN checks the format and the check digit of the ISIN: letters
+// are expanded to two digits (A=10 ... Z=35) and the result must pass the
+// Luhn algorithm
+func ValidateISIN(id string) error {
+	if !LooksLikeISIN(id) {
+		return fmt.Errorf("%w: '%s' (expected 2 letters, 9 letters or digits and a check digit)", ErrInvalidISIN, id)
+	}
+
+	var digits strings.Builder
+
+	for _, c := range id {
+		if c >= 'A' && c <= 'Z' {
+			fmt.Fprintf(&digits, "%d", c-'A'+10)
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+
+	d := digits.String()
+	sum := 0
+
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+
+		if (len(d)-1-i)%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+
+		sum += n
+	}
+
+	if sum%10 != 0 {
+		return fmt.Errorf("%w: '%s' (wrong check digit)", ErrInvalidISIN, id)
+	}
+
+	return nil
+}
+
+// ResolveIdentifier returns the listings that match the identifier (ticker,
+// WKN, Valoren, SEDOL, ...) in the searchable sources; listings without an
+// ISIN get one from ListingISIN once picked
+func (db *DB) ResolveIdentifier(ctx context.Context, id string, sources []string) ([]Listing, error) {
+	var (
+		result   []Listing
+		searched bool
+	)
+
+	seen := map[string]bool{}
+
+	for _, name := range sources {
+		s, err := db.Source(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.Search == nil {
+			continue
+		}
+
+		searched = true
+
+		listings, err := s.Search(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range listings {
+			if l.ISIN != "" && !IsCrypto(l.ISIN) && ValidateISIN(l.ISIN) != nil {
+				continue
+			}
+
+			key := l.Source + ":" + l.SourceID + ":" + l.Symbol
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+
+			result = append(result, l)
+		}
+	}
+
+	if searched && len(result) == 0 {
+		return nil, fmt.Errorf("%w: '%s'", ErrNotResolved, id)
+	}
+
+	return result, nil
+}
+
+// ListingISIN looks up the ISIN of a listing the search returned without one
+func (db *DB) ListingISIN(ctx context.Context, l *Listing) (string, error) {
+	s, err := db.Source(l.Source)
+	if err != nil {
+		return "", err
+	}
+
+	if s.ListingISIN == nil {
+		return "", fmt.Errorf("%w: '%s' at %s", ErrNotResolved, l.Symbol, l.Source)
+	}
+
+	isin, err := s.ListingISIN(ctx, l)
+	if err != nil {
+		return "", err
+	}
+
+	if isin == "" {
+		return "", fmt.Errorf("%w: '%s' at %s", ErrNotResolved, l.Symbol, l.Source)
+	}
+
+	return isin, nil
+}
+
+// pageISIN fetches the page and returns the ISIN shown on it
+func (db *DB) pageISIN(ctx context.Context, client *retryablehttp.Client, pageURL string) (string, error) {
+	req, err := retryablehttp.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := doRequest(withMetaRequest(ctx), client, req)
+	if err != nil {
+		return "", err
+	}
+
+	return findISIN(string(body)), nil
+}
+
+// findISIN returns the first valid ISIN shortly after an 'ISIN' label in the
+// page, or an empty string
+func findISIN(page string) string {
+	for _, m := range isinLabel.FindAllStringIndex(page, -1) {
+		end := m[1] + isinWindow
+		if end > len(page) {
+			end = len(page)
+		}
+
+		for _, c := range isinCandidate.FindAllString(page[m[1]:end], -1) {
+			if ValidateISIN(c) == nil {
+				return c
+			}
+		}
+	}
+
+	return ""
+}
+
+// Apply copies the exchange, currency and source identifier of the listing
+// into the options, unless they were set explicitly
+func (l *Listing) Apply(opts ISINOptions) ISINOptions {
+	if l == nil {
+		return opts
+	}
+
+	if opts.Currency == "" {
+		opts.Currency = l.Currency
+	}
+
+	if opts.Exchange == "" {
+		opts.Exchange = l.Exchange
+	}
+
+	if l.SourceID != "" {
+		opts.SourceIDs = map[string]string{l.Source: l.SourceID}
+	}
+
+	return opts
+}
+
+// matchListings returns the listings on the exchange and in the currency;
+// empty criteria, and listings that do not report the field, match anything
+func matchListings(listings []Listing, exchange, currency string) []Listing {
+	var result []Listing
+
+	for _, l := range listings {
+		if exchange != "" && l.Exchange != "" && !strings.EqualFold(exchange, l.Exchange) {
+			continue
+		}
+
+		if currency != "" && l.Currency != "" && !strings.EqualFold(currency, l.Currency) {
+			continue
+		}
+
+		result = append(result, l)
+	}
+
+	return result
+}
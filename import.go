@@ -7,7 +7,18 @@ import (
 	"github.com/asdine/storm/v3"
 )
 
+// ImportValuations stores the valuations that pass validation; the others
+// are quarantined
 func (db *DB) ImportValuations(isin *ISIN, valuations []*Valuation) error {
+	valuations, err := db.validateValuations(isin, valuations)
+	if err != nil {
+		return err
+	}
+
+	return db.importValuations(isin, valuations)
+}
+
+func (db *DB) importValuations(isin *ISIN, valuations []*Valuation) error {
 	var newR Valuation
 
 	sort.Slice(valuations, func(i, j int) bool {
@@ -18,7 +29,7 @@ func (db *DB) ImportValuations(isin *ISIN, valuations []*Valuation) error {
 		val.UpdateID()
 
 		if isin.UpdatedAt.Before(val.Date) {
-			isin.ValuePerShare = val.Value()
+			isin.ValuePerShare = db.ValueOf(isin, val)
 			isin.UpdatedAt = val.Date
 
 			db.logger.Infof("New value for '%s': %s %.2f (%s)", isin.ID, isin.Nomination, isin.ValuePerShare, isin.UpdatedAt.UTC())
@@ -29,8 +40,22 @@ func (db *DB) ImportValuations(isin *ISIN, valuations []*Valuation) error {
 		}
 
 		err := db.DB().One("ID", val.ID, &newR)
+		if err == nil && val.Source == DataSourceManual {
+			db.logger.Infof("Replacing entry: %#v", val.ID)
+
+			if err := db.DB().Save(val); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		if err == nil {
 			// We have it already...
+			if err := db.CheckConflict(isin, &newR, val); err != nil {
+				return err
+			}
+
 			continue
 		}
 
@@ -45,5 +70,9 @@ func (db *DB) ImportValuations(isin *ISIN, valuations []*Valuation) error {
 		}
 	}
 
-	return nil
+	if err := db.RefreshValuePerShare(isin); err != nil {
+		return err
+	}
+
+	return db.EvaluateAlerts(isin)
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommandSource runs an external command to fetch the valuations of an ISIN.
+// The command gets the ISIN, its identifier at the source, and the first and
+// last date to fetch (YYYY-MM-DD; the first is empty for the full history)
+// as arguments, after the configured ones, and in FINTRK_* environment
+// variables. It prints one JSON object per line: valuations have a date and
+// a value or open/high/low/close, other lines may set name, currency,
+// asset_class and id of the ISIN.
+type CommandSource struct {
+	Name    string
+	Command string
+	Args    []string
+
+	db *DB
+}
+
+// commandLine is a single line of output of a command source
+type commandLine struct {
+	Date       string   `json:"date"`
+	Value      *float64 `json:"value"`
+	Open       float64  `json:"open"`
+	High       float64  `json:"high"`
+	Low        float64  `json:"low"`
+	Close      float64  `json:"close"`
+	Name       string   `json:"name"`
+	Currency   string   `json:"currency"`
+	AssetClass string   `json:"asset_class"`
+	ID         string   `json:"id"`
+}
+
+func (s *CommandSource) Update(ctx context.Context, isin *ISIN) error {
+	since, err := s.db.UpdateSince(isin, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	return s.run(ctx, isin, since)
+}
+
+func (s *CommandSource) Backfill(ctx context.Context, isin *ISIN, since time.Time) error {
+	return s.run(ctx, isin, since)
+}
+
+// Fetch returns the valuations since the date without storing them
+func (s *CommandSource) Fetch(ctx context.Context, isin *ISIN, since time.Time) ([]*Valuation, error) {
+	return s.fetch(ctx, isin, since)
+}
+
+func (s *CommandSource) run(ctx context.Context, isin *ISIN, since time.Time) error {
+	vals, err := s.fetch(ctx, isin, since)
+	if err != nil {
+		return err
+	}
+
+	return s.db.ImportValuations(isin, vals)
+}
+
+func (s *CommandSource) fetch(ctx context.Context, isin *ISIN, since time.Time) ([]*Valuation, error) {
+	from := ""
+	if !since.IsZero() {
+		from = timeToDate(&since)
+	}
+
+	now := s.db.Now()
+	to := timeToDate(&now)
+	id := isin.SourceID(s.Name)
+
+	args := append(append([]string{}, s.Args...), isin.ID, id, from, to)
+
+	s.db.logger.Debugf("Running source '%s': %s %v", s.Name, s.Command, args)
+
+	cmd := exec.CommandContext(ctx, s.Command, args...) //nolint:gosec
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"FINTRK_ISIN="+isin.ID,
+		"FINTRK_ID="+id,
+		"FINTRK_FROM="+from,
+		"FINTRK_TO="+to,
+	)
+
+	out, err := runCommand(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("source '%s': %w", s.Name, err)
+	}
+
+	vals, err := s.parse(isin, out)
+	if err != nil {
+		return nil, fmt.Errorf("source '%s': %w", s.Name, err)
+	}
+
+	if err := s.db.DB().Save(isin); err != nil {
+		return nil, err
+	}
+
+	s.db.logger.Debugf("got %d valuations", len(vals))
+
+	return vals, nil
+}
+
+func (s *CommandSource) parse(isin *ISIN, out []byte) ([]*Valuation, error) {
+	var vals []*Valuation
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		var l commandLine
+
+		if err := json.Unmarshal(b, &l); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if l.Date == "" {
+			s.applyMeta(isin, &l)
+			continue
+		}
+
+		d, err := time.Parse("2006-01-02", l.Date)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		v := Valuation{
+			ISIN:   isin.ID,
+			Date:   d,
+			Open:   l.Open,
+			High:   l.High,
+			Low:    l.Low,
+			Close:  l.Close,
+			Source: s.Name,
+		}
+
+		if l.Value != nil {
+			v.Open, v.High, v.Low, v.Close = *l.Value, *l.Value, *l.Value, *l.Value
+		}
+
+		vals = append(vals, &v)
+	}
+
+	return vals, scanner.Err()
+}
+
+func (s *CommandSource) applyMeta(isin *ISIN, l *commandLine) {
+	if l.Name != "" {
+		isin.Name = l.Name
+	}
+
+	switch {
+	case l.Currency == "":
+	case isin.Nomination == "":
+		isin.Nomination = l.Currency
+	case !strings.EqualFold(isin.Nomination, l.Currency):
+		s.db.logger.Warnf("Source '%s' reports '%s' in %s, keeping %s", s.Name, isin.ID, l.Currency, isin.Nomination)
+	}
+
+	if l.AssetClass != "" {
+		isin.AssetClass = l.AssetClass
+	}
+
+	if l.ID != "" {
+		isin.SetSourceID(s.Name, l.ID)
+	}
+}
+
+// runCommand runs the command and returns its output; unlike cmd.Output, it
+// returns as soon as the context is done, even when processes started by the
+// command still hold on to its stdout
+func runCommand(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	cmd.Stdout = w
+
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	w.Close()
+
+	type result struct {
+		out []byte
+		err error
+	}
+
+	read := make(chan result, 1)
+
+	go func() {
+		out, err := ioutil.ReadAll(r)
+		read <- result{out, err}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	select {
+	case res := <-read:
+		return res.out, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
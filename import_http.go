@@ -1,24 +1,237 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
 )
 
 const (
 	DataSourceFT        = "FT"
 	DataSourceInvesting = "investing"
+
+	// crossCheckDays is how far back the other sources of a chain are
+	// compared with the prices just stored
+	crossCheckDays = 7
 )
 
-var ErrUnknownSource = errors.New("unknown data source")
+var (
+	ErrUnknownSource   = errors.New("unknown data source")
+	ErrBackfillSource  = errors.New("data source does not support backfilling")
+	ErrNoBackfillStart = errors.New("no start date and no transactions to backfill from")
+	ErrNoData          = errors.New("no data found")
+	ErrAllSources      = errors.New("all data sources failed")
+)
+
+// Source fetches data of an ISIN; Backfill and Fetch are nil when the source
+// cannot fetch history from an arbitrary date, Search when it cannot look up
+// identifiers, and ListingISIN when it cannot find the ISIN of a listing its
+// search returned without one. Fetch returns the valuations without storing
+// them
+type Source struct {
+	Update      func(ctx context.Context, isin *ISIN) error
+	Backfill    func(ctx context.Context, isin *ISIN, since time.Time) error
+	Fetch       func(ctx context.Context, isin *ISIN, since time.Time) ([]*Valuation, error)
+	Search      func(ctx context.Context, query string) ([]Listing, error)
+	ListingISIN func(ctx context.Context, l *Listing) (string, error)
+}
 
-func (db *DB) UpdateFromHTTP(isin *ISIN) error {
-	switch isin.Source {
+// Source returns the built-in source, or the command source defined in the
+// configuration, with the name
+func (db *DB) Source(name string) (*Source, error) {
+	switch name {
 	case DataSourceFT:
-		return db.FTUpdateFromHTTP(isin)
+		return &Source{
+			Update: db.FTUpdateFromHTTP, Backfill: db.FTBackfillFromHTTP, Fetch: db.FTFetchFromHTTP,
+			Search: db.FTSearch, ListingISIN: db.FTListingISIN,
+		}, nil
 	case DataSourceInvesting:
-		return db.InvestingUpdateFromHTTP(isin)
-	default:
-		return fmt.Errorf("%w: '%s'", ErrUnknownSource, isin.Source)
+		return &Source{
+			Update: db.InvestingUpdateFromHTTP, Backfill: db.InvestingBackfillFromHTTP, Fetch: db.InvestingFetchFromHTTP,
+			Search: db.InvestingSearch, ListingISIN: db.InvestingListingISIN,
+		}, nil
+	case DataSourceCoinGecko:
+		return &Source{
+			Update: db.CoinGeckoUpdateFromHTTP, Backfill: db.CoinGeckoBackfillFromHTTP, Fetch: db.CoinGeckoFetchFromHTTP,
+			Search: db.CoinGeckoSearch,
+		}, nil
+	case DataSourceManual:
+		return &Source{Update: db.ManualUpdate}, nil
+	}
+
+	if c, ok := db.config.Sources[name]; ok && c.Command != "" {
+		s := CommandSource{Name: name, Command: c.Command, Args: c.Args, db: db}
+
+		return &Source{Update: s.Update, Backfill: s.Backfill, Fetch: s.Fetch}, nil
+	}
+
+	return nil, fmt.Errorf("%w: '%s'", ErrUnknownSource, name)
+}
+
+func (db *DB) ValidateSource(name string) error {
+	_, err := db.Source(name)
+
+	return err
+}
+
+// UpdateFromHTTP tries the sources of the ISIN in order, until one of them
+// succeeds, and compares the recent prices with the other sources
+func (db *DB) UpdateFromHTTP(ctx context.Context, isin *ISIN) error {
+	_, err := db.updateFromChain(ctx, isin)
+
+	return err
+}
+
+// updateFromChain returns the source that succeeded
+func (db *DB) updateFromChain(ctx context.Context, isin *ISIN) (string, error) {
+	var (
+		errs    []string
+		lastErr error
+	)
+
+	for _, source := range isin.SourceChain() {
+		err := db.updateFromSource(ctx, isin, source)
+
+		if statsErr := db.RecordUpdateRun(source, err == nil); statsErr != nil {
+			db.logger.Errorf("Error recording update for ISIN '%s': %v", isin.ID, statsErr)
+		}
+
+		if err == nil {
+			db.crossCheck(ctx, isin, source)
+
+			return source, nil
+		}
+
+		if len(isin.SourceChain()) > 1 {
+			db.logger.Warnf("Source '%s' failed for ISIN '%s': %v", source, isin.ID, err)
+		}
+
+		errs = append(errs, fmt.Sprintf("%s: %v", source, err))
+		lastErr = err
+	}
+
+	if len(errs) == 1 {
+		return "", lastErr
 	}
+
+	return "", fmt.Errorf("%w: %s", ErrAllSources, strings.Join(errs, "; "))
+}
+
+// crossCheck compares the recent prices stored from the source with those of
+// the other sources in the chain, and records the conflicts; the other
+// sources failing does not fail the update
+func (db *DB) crossCheck(ctx context.Context, isin *ISIN, source string) {
+	since := db.Today().AddDate(0, 0, -crossCheckDays)
+
+	for _, other := range isin.SourceChain() {
+		if other == source {
+			continue
+		}
+
+		if err := db.crossCheckSource(ctx, isin, other, since); err != nil {
+			db.logger.Warnf("Could not compare '%s' with source '%s': %v", isin.ID, other, err)
+		}
+	}
+}
+
+func (db *DB) crossCheckSource(ctx context.Context, isin *ISIN, name string, since time.Time) error {
+	s, err := db.Source(name)
+	if err != nil {
+		return err
+	}
+
+	if s.Fetch == nil {
+		return nil
+	}
+
+	vals, err := s.Fetch(ctx, isin, since)
+	if err != nil {
+		return err
+	}
+
+	for _, val := range vals {
+		val.UpdateID()
+
+		var stored Valuation
+
+		err := db.DB().One("ID", val.ID, &stored)
+		if errors.Is(err, storm.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := db.CheckConflict(isin, &stored, val); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) updateFromSource(ctx context.Context, isin *ISIN, name string) error {
+	s, err := db.Source(name)
+	if err != nil {
+		return err
+	}
+
+	return s.Update(ctx, isin)
+}
+
+// Backfill fetches all valuations of the ISIN since the date, or since its
+// first transaction when the date is zero, from the first source that
+// supports it
+func (db *DB) Backfill(ctx context.Context, isinID string, since time.Time) error {
+	defer db.SendAlerts(ctx)
+
+	isin, err := db.GetISIN(isinID)
+	if err != nil {
+		return err
+	}
+
+	if since.IsZero() {
+		if since, err = db.GetFirstTransactionDate(isinID); err != nil {
+			if errors.Is(err, storm.ErrNotFound) {
+				return ErrNoBackfillStart
+			}
+
+			return err
+		}
+	}
+
+	db.logger.Infof("Backfilling ISIN '%s' since %s", isin.ID, timeToDate(&since))
+
+	for _, name := range isin.SourceChain() {
+		s, err := db.Source(name)
+		if err != nil {
+			return err
+		}
+
+		if s.Backfill != nil {
+			return s.Backfill(ctx, isin, since)
+		}
+	}
+
+	return fmt.Errorf("%w: '%s'", ErrBackfillSource, strings.Join(isin.SourceChain(), ", "))
+}
+
+// UpdateSince returns the date of the last stored valuation of the ISIN, or
+// the fallback when there are none yet
+func (db *DB) UpdateSince(isin *ISIN, fallback time.Time) (time.Time, error) {
+	v, err := db.GetValuation(isin.ID)
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return fallback, nil
+		}
+
+		return time.Time{}, err
+	}
+
+	return v.Date, nil
 }
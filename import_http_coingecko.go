@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// DataSourceCoinGecko provides daily prices of cryptocurrencies
+	DataSourceCoinGecko = "coingecko"
+
+	coinGeckoURL = "https://api.coingecko.com/api/v3"
+
+	// coinGeckoHistoryDays is the history fetched for a new cryptocurrency
+	coinGeckoHistoryDays = 365
+)
+
+type CoinGeckoSearchResponse struct {
+	Coins []CoinGeckoCoin `json:"coins"`
+}
+
+type CoinGeckoCoin struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Symbol        string `json:"symbol"`
+	MarketCapRank int    `json:"market_cap_rank"`
+}
+
+type CoinGeckoMarketChart struct {
+	Prices [][2]float64 `json:"prices"`
+}
+
+func (db *DB) CoinGeckoUpdateFromHTTP(ctx context.Context, isin *ISIN) error {
+	client := db.NewHTTPClient(DataSourceCoinGecko)
+
+	if err := db.CoinGeckoUpdateMetaFromHTTP(ctx, isin, client); err != nil {
+		return err
+	}
+
+	since, err := db.UpdateSince(isin, db.Now().AddDate(0, 0, -coinGeckoHistoryDays))
+	if err != nil {
+		return err
+	}
+
+	return db.CoinGeckoUpdateValuationsFromHTTP(ctx, isin, client, since)
+}
+
+// CoinGeckoBackfillFromHTTP fetches all daily prices since the date
+func (db *DB) CoinGeckoBackfillFromHTTP(ctx context.Context, isin *ISIN, since time.Time) error {
+	client := db.NewHTTPClient(DataSourceCoinGecko)
+
+	if err := db.CoinGeckoUpdateMetaFromHTTP(ctx, isin, client); err != nil {
+		return err
+	}
+
+	return db.CoinGeckoUpdateValuationsFromHTTP(ctx, isin, client, since)
+}
+
+// CoinGeckoFetchFromHTTP returns the daily prices since the date without
+// storing them
+func (db *DB) CoinGeckoFetchFromHTTP(ctx context.Context, isin *ISIN, since time.Time) ([]*Valuation, error) {
+	client := db.NewHTTPClient(DataSourceCoinGecko)
+
+	if err := db.CoinGeckoUpdateMetaFromHTTP(ctx, isin, client); err != nil {
+		return nil, err
+	}
+
+	return db.coinGeckoValuationsSince(ctx, isin, client, since)
+}
+
+// CoinGeckoUpdateMetaFromHTTP looks up the coin of the symbol, preferring the
+// one with the largest market cap; prices are fetched in the base currency
+// unless the nomination was set before
+func (db *DB) CoinGeckoUpdateMetaFromHTTP(ctx context.Context, isin *ISIN, client *retryablehttp.Client) error {
+	if !IsCrypto(isin.ID) {
+		return fmt.Errorf("%w: '%s' is not a cryptocurrency (expected %s<symbol>)", ErrNoData, isin.ID, cryptoPrefix)
+	}
+
+	if isin.Nomination == "" {
+		if db.config.BaseCurrency == "" {
+			return fmt.Errorf("%w: set it or use --currency for '%s'", ErrNoBaseCurrency, isin.ID)
+		}
+
+		isin.Nomination = db.config.BaseCurrency
+	}
+
+	if isin.SourceID(DataSourceCoinGecko) == "" {
+		symbol := strings.TrimPrefix(isin.ID, cryptoPrefix)
+
+		coins, err := db.coinGeckoSearch(ctx, client, symbol)
+		if err != nil {
+			return err
+		}
+
+		c := coinGeckoPick(coins, symbol)
+		if c == nil {
+			return fmt.Errorf("%w: no coin with symbol '%s' at CoinGecko", ErrNoData, symbol)
+		}
+
+		isin.SetSourceID(DataSourceCoinGecko, c.ID)
+
+		if isin.Name == "" {
+			isin.Name = c.Name
+		}
+	}
+
+	isin.AssetClass = "crypto"
+
+	return db.DB().Save(isin)
+}
+
+// coinGeckoPick returns the coin with the symbol and the best market cap rank
+func coinGeckoPick(coins []CoinGeckoCoin, symbol string) *CoinGeckoCoin {
+	var best *CoinGeckoCoin
+
+	rank := func(c *CoinGeckoCoin) int {
+		if c.MarketCapRank == 0 {
+			return math.MaxInt32
+		}
+
+		return c.MarketCapRank
+	}
+
+	for k := range coins {
+		c := &coins[k]
+
+		if !strings.EqualFold(c.Symbol, symbol) {
+			continue
+		}
+
+		if best == nil || rank(c) < rank(best) {
+			best = c
+		}
+	}
+
+	return best
+}
+
+// CoinGeckoSearch returns the coins CoinGecko finds for the query, as
+// CRYPTO:<symbol> listings
+func (db *DB) CoinGeckoSearch(ctx context.Context, query string) ([]Listing, error) {
+	coins, err := db.coinGeckoSearch(ctx, db.NewHTTPClient(DataSourceCoinGecko), query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Listing, 0, len(coins))
+
+	for _, c := range coins {
+		result = append(result, Listing{
+			ISIN:       CryptoID(c.Symbol),
+			SourceID:   c.ID,
+			Symbol:     strings.ToUpper(c.Symbol),
+			Name:       c.Name,
+			AssetClass: "crypto",
+			Source:     DataSourceCoinGecko,
+		})
+	}
+
+	return result, nil
+}
+
+func (db *DB) coinGeckoSearch(ctx context.Context, client *retryablehttp.Client, query string) ([]CoinGeckoCoin, error) {
+	req, err := db.coinGeckoRequest("/search", url.Values{"query": []string{query}})
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := doRequest(withMetaRequest(ctx), client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var output CoinGeckoSearchResponse
+
+	if err := json.Unmarshal(body, &output); err != nil {
+		return nil, err
+	}
+
+	return output.Coins, nil
+}
+
+func (db *DB) CoinGeckoUpdateValuationsFromHTTP(ctx context.Context, isin *ISIN, client *retryablehttp.Client, since time.Time) error {
+	vals, err := db.coinGeckoValuationsSince(ctx, isin, client, since)
+	if err != nil {
+		return err
+	}
+
+	return db.ImportValuations(isin, vals)
+}
+
+func (db *DB) coinGeckoValuationsSince(ctx context.Context, isin *ISIN, client *retryablehttp.Client, since time.Time) ([]*Valuation, error) {
+	days := daysBetween(since, db.Now())
+
+	req, err := db.coinGeckoRequest("/coins/"+url.PathEscape(isin.SourceID(DataSourceCoinGecko))+"/market_chart", url.Values{
+		"vs_currency": []string{strings.ToLower(isin.Nomination)},
+		"days":        []string{strconv.Itoa(days)},
+		"interval":    []string{"daily"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := doRequest(ctx, client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var output CoinGeckoMarketChart
+
+	if err := json.Unmarshal(body, &output); err != nil {
+		return nil, err
+	}
+
+	vals := coinGeckoToValuations(isin.ID, output)
+
+	db.logger.Debugf("got %d valuations", len(vals))
+
+	return vals, nil
+}
+
+// coinGeckoToValuations keeps the last price of every day; CoinGecko only
+// reports a single price, which is used for all fields
+func coinGeckoToValuations(isin string, chart CoinGeckoMarketChart) []*Valuation {
+	var result []*Valuation
+
+	byDay := map[time.Time]*Valuation{}
+
+	for _, p := range chart.Prices {
+		t := time.Unix(0, int64(p[0])*int64(time.Millisecond)).UTC()
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+		v, ok := byDay[day]
+		if !ok {
+			v = &Valuation{ISIN: isin, Date: day, Source: DataSourceCoinGecko}
+			byDay[day] = v
+			result = append(result, v)
+		}
+
+		v.Open, v.High, v.Low, v.Close = p[1], p[1], p[1], p[1]
+	}
+
+	return result
+}
+
+func (db *DB) coinGeckoRequest(path string, query url.Values) (*retryablehttp.Request, error) {
+	u, err := url.Parse(coinGeckoURL + path)
+	if err != nil {
+		return nil, err
+	}
+
+	u.RawQuery = query.Encode()
+
+	req, err := retryablehttp.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if key := db.config.Sources[DataSourceCoinGecko].APIKey; key != "" {
+		req.Header.Set("x-cg-demo-api-key", key)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCoinGeckoUpdateFromHTTP(t *testing.T) {
+	db := newReplayDB(t, "testdata/coingecko")
+	isin := &ISIN{ID: "CRYPTO:BTC", Nomination: "EUR", Source: DataSourceCoinGecko}
+
+	if err := db.CoinGeckoUpdateFromHTTP(context.Background(), isin); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db.GetISIN(isin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := stored.SourceID(DataSourceCoinGecko); got != "bitcoin" {
+		t.Errorf("CoinGecko identifier is '%s', want 'bitcoin'", got)
+	}
+
+	if stored.Name != "Bitcoin" || stored.AssetClass != "crypto" {
+		t.Errorf("name and asset class are '%s' and '%s', want 'Bitcoin' and 'crypto'", stored.Name, stored.AssetClass)
+	}
+
+	// The last price of every day is kept
+	want := []Valuation{
+		{Date: time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC), Open: 66480.12},
+		{Date: time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), Open: 67102.77},
+		{Date: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), Open: 63951.3},
+	}
+
+	vals := storedValuations(t, db, isin.ID)
+	if len(vals) != len(want) {
+		t.Fatalf("got %d valuations, want %d", len(vals), len(want))
+	}
+
+	for k, w := range want {
+		v := vals[k]
+
+		if !v.Date.Equal(w.Date) || v.Open != w.Open || v.High != w.Open || v.Low != w.Open || v.Close != w.Open || v.Source != DataSourceCoinGecko {
+			t.Errorf("valuation %d is %+v, want %+v", k, v, w)
+		}
+	}
+}
+
+func TestCoinGeckoPick(t *testing.T) {
+	tests := []struct {
+		name   string
+		coins  []CoinGeckoCoin
+		symbol string
+		want   string
+	}{
+		{
+			name: "best rank",
+			coins: []CoinGeckoCoin{
+				{ID: "bitcoin-token", Symbol: "btc", MarketCapRank: 2500},
+				{ID: "bitcoin", Symbol: "BTC", MarketCapRank: 1},
+			},
+			symbol: "BTC",
+			want:   "bitcoin",
+		},
+		{
+			name: "unranked last",
+			coins: []CoinGeckoCoin{
+				{ID: "batcat", Symbol: "BTC"},
+				{ID: "bitcoin-token", Symbol: "btc", MarketCapRank: 2500},
+			},
+			symbol: "btc",
+			want:   "bitcoin-token",
+		},
+		{
+			name:   "only unranked",
+			coins:  []CoinGeckoCoin{{ID: "batcat", Symbol: "BTC"}},
+			symbol: "BTC",
+			want:   "batcat",
+		},
+		{
+			name:   "other symbols",
+			coins:  []CoinGeckoCoin{{ID: "wrapped-bitcoin", Symbol: "WBTC", MarketCapRank: 15}},
+			symbol: "BTC",
+		},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if c := coinGeckoPick(tt.coins, tt.symbol); c != nil {
+			got = c.ID
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: picked '%s', want '%s'", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"html"
-	"io/ioutil"
 	"net/url"
 	"regexp"
 	"strings"
@@ -37,6 +38,7 @@ type FTSeriesComponent struct {
 
 type FTSeriesQuery struct {
 	Days              int                    `json:"days"`
+	EndOffsetDays     int                    `json:"endOffsetDays,omitempty"`
 	DataNormalized    bool                   `json:"dataNormalized"`
 	DataPeriod        string                 `json:"dataPeriod"`
 	DataInterval      int                    `json:"dataInterval"`
@@ -67,11 +69,17 @@ type FTSearch struct {
 	} `json:"data"`
 }
 
+// ftMaxDays is the largest window the FT chart API returns in one request
+const ftMaxDays = 1000
+
 var extractFTInfo = regexp.MustCompile(`.*<section class="mod-tearsheet-add-to-watchlist" data-mod-config="([^"]+)".*`)
 
-func (i *ISIN) BuildFTSeriesQuery(days int) FTSeriesQuery {
+// BuildFTSeriesQuery requests the daily prices of the window of days, ending
+// endOffsetDays before today
+func (i *ISIN) BuildFTSeriesQuery(days int, endOffsetDays int) FTSeriesQuery {
 	return FTSeriesQuery{
 		Days:              days,
+		EndOffsetDays:     endOffsetDays,
 		DataPeriod:        "Day",
 		DataInterval:      1,
 		TimeServiceFormat: "JSON",
@@ -80,53 +88,81 @@ func (i *ISIN) BuildFTSeriesQuery(days int) FTSeriesQuery {
 			{
 				Label:  "3ec7c513",
 				Type:   "price",
-				Symbol: i.XID,
+				Symbol: i.SourceID(DataSourceFT),
 			},
 		},
 	}
 }
 
-func (db *DB) FTUpdateFromHTTP(isin *ISIN) error {
-	client := retryablehttp.NewClient()
-	client.Logger = db.logger
+func (db *DB) FTUpdateFromHTTP(ctx context.Context, isin *ISIN) error {
+	client := db.NewHTTPClient(DataSourceFT)
 
-	if err := db.FTUpdateXIDFromHTTP(isin, client); err != nil {
+	if err := db.FTUpdateXIDFromHTTP(ctx, isin, client); err != nil {
 		return err
 	}
 
-	if err := db.FTUpdateMetaFromHTTP(isin, client); err != nil {
+	if err := db.FTUpdateMetaFromHTTP(ctx, isin, client); err != nil {
 		return err
 	}
 
-	return db.FTUpdateValuationsFromHTTP(isin, client)
-}
-
-func (db *DB) FTUpdateXIDFromHTTP(isin *ISIN, client *retryablehttp.Client) error {
-	u, err := url.Parse("https://markets.ft.com/data/funds/tearsheet/charts?s=" + isin.ISINNomination())
+	since, err := db.UpdateSince(isin, db.Now().AddDate(0, 0, -ftMaxDays))
 	if err != nil {
 		return err
 	}
 
-	req, err := retryablehttp.NewRequest("GET", u.String(), nil)
+	return db.FTUpdateValuationsFromHTTP(ctx, isin, client, since)
+}
+
+// FTBackfillFromHTTP fetches all valuations since the date
+func (db *DB) FTBackfillFromHTTP(ctx context.Context, isin *ISIN, since time.Time) error {
+	client := db.NewHTTPClient(DataSourceFT)
+
+	if isin.SourceID(DataSourceFT) == "" {
+		if err := db.FTUpdateXIDFromHTTP(ctx, isin, client); err != nil {
+			return err
+		}
+	}
+
+	return db.FTUpdateValuationsFromHTTP(ctx, isin, client, since)
+}
+
+// FTFetchFromHTTP returns the valuations since the date without storing them
+func (db *DB) FTFetchFromHTTP(ctx context.Context, isin *ISIN, since time.Time) ([]*Valuation, error) {
+	client := db.NewHTTPClient(DataSourceFT)
+
+	if isin.SourceID(DataSourceFT) == "" {
+		if err := db.FTUpdateXIDFromHTTP(ctx, isin, client); err != nil {
+			return nil, err
+		}
+	}
+
+	return db.ftValuationsSince(ctx, isin, client, since)
+}
+
+func (db *DB) FTUpdateXIDFromHTTP(ctx context.Context, isin *ISIN, client *retryablehttp.Client) error {
+	u, err := url.Parse("https://markets.ft.com/data/funds/tearsheet/charts?s=" + isin.ISINNomination())
 	if err != nil {
 		return err
 	}
 
-	resp, err := client.Do(req)
+	req, err := retryablehttp.NewRequest("GET", u.String(), nil)
 	if err != nil {
 		return err
 	}
 
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := doRequest(withMetaRequest(ctx), client, req)
 	if err != nil {
 		return err
 	}
 
 	parsed := extractFTInfo.FindStringSubmatch(string(body))
 	if len(parsed) < 2 {
-		return nil
+		if isin.SourceID(DataSourceFT) != "" {
+			db.logger.Warnf("Could not find FT identifier for '%s', using the known one", isin.ID)
+			return nil
+		}
+
+		return fmt.Errorf("%w: no FT identifier for '%s'", ErrNoData, isin.ID)
 	}
 
 	j := html.UnescapeString(parsed[1])
@@ -137,98 +173,217 @@ func (db *DB) FTUpdateXIDFromHTTP(isin *ISIN, client *retryablehttp.Client) erro
 		return err
 	}
 
-	isin.XID = d.XID
+	isin.SetSourceID(DataSourceFT, d.XID)
 
 	return db.DB().Save(isin)
 }
 
-func (db *DB) FTUpdateMetaFromHTTP(isin *ISIN, client *retryablehttp.Client) error {
-	u, err := url.Parse("https://markets.ft.com/data/searchapi/searchsecurities?query=" + isin.ISINNomination())
+// FTUpdateMetaFromHTTP refreshes the name and asset class from the FT listing
+// on the exchange and in the currency of the ISIN; the nomination is only
+// set when it is still unknown, so it never changes between updates
+func (db *DB) FTUpdateMetaFromHTTP(ctx context.Context, isin *ISIN, client *retryablehttp.Client) error {
+	listings, err := db.ftSearch(ctx, client, isin.ISINNomination())
 	if err != nil {
 		return err
 	}
 
-	req, err := retryablehttp.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return err
+	matches := matchListings(listings, isin.Exchange, isin.Nomination)
+	if len(matches) == 0 {
+		if len(listings) > 0 {
+			db.logger.Warnf("No FT listing for '%s' on exchange '%s' in currency '%s'", isin.ID, isin.Exchange, isin.Nomination)
+		}
+
+		return nil
+	}
+
+	l := matches[0]
+
+	isin.Name = l.Name
+	isin.AssetClass = l.AssetClass
+
+	if isin.Nomination == "" {
+		isin.Nomination = l.Currency
+	}
+
+	if isin.Exchange == "" {
+		isin.Exchange = l.Exchange
 	}
 
-	resp, err := client.Do(req)
+	return db.DB().Save(isin)
+}
+
+// FTSearch returns the listings FT finds for the query
+func (db *DB) FTSearch(ctx context.Context, query string) ([]Listing, error) {
+	return db.ftSearch(ctx, db.NewHTTPClient(DataSourceFT), query)
+}
+
+func (db *DB) ftSearch(ctx context.Context, client *retryablehttp.Client, query string) ([]Listing, error) {
+	u, err := url.Parse("https://markets.ft.com/data/searchapi/searchsecurities?query=" + url.QueryEscape(query))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
+	req, err := retryablehttp.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := doRequest(withMetaRequest(ctx), client, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var output FTSearch
 
 	if err := json.Unmarshal(body, &output); err != nil {
-		return err
+		return nil, err
 	}
 
-	if len(output.Data.Security) == 0 {
-		return nil
+	queryISIN := strings.SplitN(query, ":", 2)[0]
+
+	var result []Listing
+
+	for _, r := range output.Data.Security {
+		result = append(result, ftListing(r.Symbol, r.Name, r.AssetClass, queryISIN))
 	}
 
-	r0 := output.Data.Security[0]
-	symb := strings.SplitN(r0.Symbol, ":", 2)
+	return result, nil
+}
 
-	isin.Name = r0.Name
-	isin.Nomination = symb[1]
-	isin.AssetClass = r0.AssetClass
+// FTListingISIN looks up the ISIN on the tearsheet of the listing
+func (db *DB) FTListingISIN(ctx context.Context, l *Listing) (string, error) {
+	return db.pageISIN(ctx, db.NewHTTPClient(DataSourceFT), ftTearsheetURL(l))
+}
 
-	return db.DB().Save(isin)
+// ftTearsheetURL returns the summary page of the listing, which depends on
+// its asset class
+func ftTearsheetURL(l *Listing) string {
+	kind := "equities"
+
+	switch class := strings.ToLower(l.AssetClass); {
+	case strings.Contains(class, "etf"):
+		kind = "etfs"
+	case strings.Contains(class, "fund"):
+		kind = "funds"
+	}
+
+	return "https://markets.ft.com/data/" + kind + "/tearsheet/summary?s=" + url.QueryEscape(l.Symbol)
 }
 
-func (db *DB) FTUpdateValuationsFromHTTP(isin *ISIN, client *retryablehttp.Client) error {
-	ftURL, err := url.Parse("https://markets.ft.com/data/chartapi/series")
-	if err != nil {
-		return err
+// ftListing parses an FT symbol: funds are 'ISIN:currency', other securities
+// 'ticker:exchange' or 'ticker:exchange:currency'; a searched ISIN is used
+// when the symbol does not contain one
+func ftListing(symbol, name, assetClass, queryISIN string) Listing {
+	parts := strings.Split(symbol, ":")
+
+	l := Listing{
+		Symbol:     symbol,
+		Name:       name,
+		AssetClass: assetClass,
+		Source:     DataSourceFT,
 	}
 
-	query := isin.BuildFTSeriesQuery(1000)
+	switch {
+	case ValidateISIN(parts[0]) == nil:
+		l.ISIN = parts[0]
 
-	j, err := json.Marshal(&query)
+		if len(parts) > 1 {
+			l.Currency = parts[len(parts)-1]
+		}
+
+		if len(parts) > 2 {
+			l.Exchange = parts[1]
+		}
+	default:
+		if ValidateISIN(queryISIN) == nil {
+			l.ISIN = queryISIN
+		}
+
+		if len(parts) > 1 {
+			l.Exchange = parts[1]
+		}
+
+		if len(parts) > 2 {
+			l.Currency = parts[2]
+		}
+	}
+
+	return l
+}
+
+// FTUpdateValuationsFromHTTP fetches and stores the valuations since the date
+func (db *DB) FTUpdateValuationsFromHTTP(ctx context.Context, isin *ISIN, client *retryablehttp.Client, since time.Time) error {
+	vals, err := db.ftValuationsSince(ctx, isin, client, since)
 	if err != nil {
 		return err
 	}
 
-	req, err := retryablehttp.NewRequest("POST", ftURL.String(), j)
+	return db.ImportValuations(isin, vals)
+}
+
+// ftValuationsSince fetches the valuations since the date, walking back in
+// windows of at most ftMaxDays days
+func (db *DB) ftValuationsSince(ctx context.Context, isin *ISIN, client *retryablehttp.Client, since time.Time) ([]*Valuation, error) {
+	var result []*Valuation
+
+	total := daysBetween(since, db.Now())
+
+	for offset := 0; offset < total; offset += ftMaxDays {
+		days := total - offset
+		if days > ftMaxDays {
+			days = ftMaxDays
+		}
+
+		db.logger.Debugf("Fetching %d days for '%s', ending %d days ago", days, isin.ID, offset)
+
+		vals, err := db.ftFetchValuations(ctx, isin, client, days, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(vals) == 0 {
+			// No more history available
+			break
+		}
+
+		result = append(result, vals...)
+	}
+
+	return result, nil
+}
+
+func (db *DB) ftFetchValuations(ctx context.Context, isin *ISIN, client *retryablehttp.Client, days int, endOffsetDays int) ([]*Valuation, error) {
+	ftURL, err := url.Parse("https://markets.ft.com/data/chartapi/series")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	query := isin.BuildFTSeriesQuery(days, endOffsetDays)
 
-	resp, err := client.Do(req)
+	j, err := json.Marshal(&query)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
+	req, err := retryablehttp.NewRequest("POST", ftURL.String(), j)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := doRequest(ctx, client, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var output FTSeries
 
 	if err = json.Unmarshal(body, &output); err != nil {
-		return err
+		return nil, err
 	}
 
-	vals, err := ftToValuaions(isin.ID, output)
-	if err != nil {
-		return err
-	}
-
-	return db.ImportValuations(isin, vals)
+	return ftToValuaions(isin.ID, output)
 }
 
 func ftToValuaions(isin string, series FTSeries) ([]*Valuation, error) {
@@ -252,12 +407,13 @@ func ftToValuaions(isin string, series FTSeries) ([]*Valuation, error) {
 		}
 
 		result[seq] = &Valuation{
-			ISIN:  isin,
-			Date:  parsed,
-			Open:  open.Values[seq],
-			High:  high.Values[seq],
-			Low:   low.Values[seq],
-			Close: closeValues.Values[seq],
+			ISIN:   isin,
+			Date:   parsed,
+			Open:   open.Values[seq],
+			High:   high.Values[seq],
+			Low:    low.Values[seq],
+			Close:  closeValues.Values[seq],
+			Source: DataSourceFT,
 		}
 	}
 
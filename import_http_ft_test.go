@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFTUpdateFromHTTP(t *testing.T) {
+	db := newReplayDB(t, "testdata/ft")
+	isin := &ISIN{ID: "IE00B4L5Y983", Source: DataSourceFT}
+
+	if err := db.FTUpdateFromHTTP(context.Background(), isin); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db.GetISIN(isin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := stored.SourceID(DataSourceFT); got != "535315994" {
+		t.Errorf("FT identifier is '%s', want '535315994'", got)
+	}
+
+	if stored.Name != "iShares Core MSCI World UCITS ETF USD (Acc)" {
+		t.Errorf("name is '%s'", stored.Name)
+	}
+
+	if stored.AssetClass != "ETF" || stored.Nomination != "EUR" {
+		t.Errorf("asset class and nomination are '%s' and '%s', want 'ETF' and 'EUR'", stored.AssetClass, stored.Nomination)
+	}
+
+	want := []Valuation{
+		{Date: time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC), Open: 88.10, High: 88.60, Low: 87.95, Close: 88.47},
+		{Date: time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), Open: 88.52, High: 89.04, Low: 88.31, Close: 88.95},
+		{Date: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), Open: 88.90, High: 89.12, Low: 88.40, Close: 88.61},
+	}
+
+	vals := storedValuations(t, db, isin.ID)
+	if len(vals) != len(want) {
+		t.Fatalf("got %d valuations, want %d", len(vals), len(want))
+	}
+
+	for k, w := range want {
+		v := vals[k]
+
+		if !v.Date.Equal(w.Date) || v.Open != w.Open || v.High != w.High || v.Low != w.Low || v.Close != w.Close || v.Source != DataSourceFT {
+			t.Errorf("valuation %d is %+v, want %+v", k, v, w)
+		}
+	}
+
+	if stored.ValuePerShare != 88.90 || !stored.UpdatedAt.Equal(want[2].Date) {
+		t.Errorf("value per share is %.2f at %s, want 88.90 at %s", stored.ValuePerShare, stored.UpdatedAt, want[2].Date)
+	}
+}
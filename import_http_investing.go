@@ -1,18 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/asdine/storm/v3"
 	"github.com/hashicorp/go-retryablehttp"
 )
 
+// investingEpoch is the earliest timestamp requested from investing.com
+const investingEpoch = 1000000000
+
+// investingURL is the site of the search and the listing pages
+const investingURL = "https://nl.investing.com"
+
 type InvestingSearchResponse struct {
 	Total struct {
 		AllResults int `json:"allResults"`
@@ -48,93 +53,198 @@ type InvestingSeries struct {
 	Status     string    `json:"s"`
 }
 
-func (db *DB) InvestingUpdateFromHTTP(isin *ISIN) error {
-	client := retryablehttp.NewClient()
-	client.Logger = db.logger
+func (db *DB) InvestingUpdateFromHTTP(ctx context.Context, isin *ISIN) error {
+	client := db.NewHTTPClient(DataSourceInvesting)
 
-	if err := db.InvestingUpdateMetaFromHTTP(isin, client); err != nil {
+	if err := db.InvestingUpdateMetaFromHTTP(ctx, isin, client); err != nil {
 		return err
 	}
 
-	if isin.XID == "" {
-		return nil
+	if isin.SourceID(DataSourceInvesting) == "" {
+		return fmt.Errorf("%w: no investing.com identifier for '%s'", ErrNoData, isin.ID)
+	}
+
+	since, err := db.UpdateSince(isin, time.Unix(investingEpoch, 0))
+	if err != nil {
+		return err
+	}
+
+	return db.InvestingUpdateValuationsFromHTTP(ctx, isin, client, since)
+}
+
+// InvestingBackfillFromHTTP fetches all valuations since the date
+func (db *DB) InvestingBackfillFromHTTP(ctx context.Context, isin *ISIN, since time.Time) error {
+	client := db.NewHTTPClient(DataSourceInvesting)
+
+	if isin.SourceID(DataSourceInvesting) == "" {
+		if err := db.InvestingUpdateMetaFromHTTP(ctx, isin, client); err != nil {
+			return err
+		}
 	}
 
-	return db.InvestingUpdateValuationsFromHTTP(isin, client)
+	return db.InvestingUpdateValuationsFromHTTP(ctx, isin, client, since)
 }
 
-func (db *DB) InvestingUpdateMetaFromHTTP(isin *ISIN, client *retryablehttp.Client) error {
-	invURL, err := url.Parse("https://nl.investing.com/search/service/searchTopBar")
+// InvestingFetchFromHTTP returns the valuations since the date without
+// storing them
+func (db *DB) InvestingFetchFromHTTP(ctx context.Context, isin *ISIN, since time.Time) ([]*Valuation, error) {
+	client := db.NewHTTPClient(DataSourceInvesting)
+
+	if isin.SourceID(DataSourceInvesting) == "" {
+		if err := db.InvestingUpdateMetaFromHTTP(ctx, isin, client); err != nil {
+			return nil, err
+		}
+	}
+
+	return db.investingValuationsSince(ctx, isin, client, since)
+}
+
+// InvestingUpdateMetaFromHTTP looks up the investing.com listing of the ISIN:
+// a known listing is kept, a new one has to be on the exchange of the ISIN
+func (db *DB) InvestingUpdateMetaFromHTTP(ctx context.Context, isin *ISIN, client *retryablehttp.Client) error {
+	quotes, err := db.investingSearch(ctx, client, isin.ID)
 	if err != nil {
 		return err
 	}
 
+	q, err := investingPickQuote(isin, quotes)
+	if err != nil || q == nil {
+		return err
+	}
+
+	isin.SetSourceID(DataSourceInvesting, strconv.Itoa(q.PairID))
+	isin.Name = q.Name
+	isin.AssetClass = q.PairType
+
+	return db.DB().Save(isin)
+}
+
+func investingPickQuote(isin *ISIN, quotes []InvestingSearchResponseQuote) (*InvestingSearchResponseQuote, error) {
+	known := isin.SourceID(DataSourceInvesting)
+
+	for k, q := range quotes {
+		if known != "" && strconv.Itoa(q.PairID) == known {
+			return &quotes[k], nil
+		}
+	}
+
+	if known != "" {
+		// Keep using the listing we had
+		return nil, nil
+	}
+
+	var exchanges []string
+
+	for k, q := range quotes {
+		if isin.Exchange == "" || strings.EqualFold(q.Exchange, isin.Exchange) {
+			return &quotes[k], nil
+		}
+
+		exchanges = append(exchanges, q.Exchange)
+	}
+
+	if len(quotes) == 0 {
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("%w: '%s' is not listed on '%s' at investing.com (found %s)", ErrNoData, isin.ID, isin.Exchange, strings.Join(exchanges, ", "))
+}
+
+// InvestingSearch returns the listings investing.com finds for the query;
+// they only have an ISIN when the query is one
+func (db *DB) InvestingSearch(ctx context.Context, query string) ([]Listing, error) {
+	quotes, err := db.investingSearch(ctx, db.NewHTTPClient(DataSourceInvesting), query)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Listing
+
+	for _, q := range quotes {
+		l := Listing{
+			SourceID:   strconv.Itoa(q.PairID),
+			URL:        investingURL + q.Link,
+			Symbol:     q.Symbol,
+			Name:       q.Name,
+			Exchange:   q.Exchange,
+			AssetClass: q.PairType,
+			Source:     DataSourceInvesting,
+		}
+
+		if ValidateISIN(query) == nil {
+			l.ISIN = query
+		}
+
+		result = append(result, l)
+	}
+
+	return result, nil
+}
+
+// InvestingListingISIN looks up the ISIN on the page of the listing
+func (db *DB) InvestingListingISIN(ctx context.Context, l *Listing) (string, error) {
+	if l.URL == "" {
+		return "", fmt.Errorf("%w: no investing.com page for '%s'", ErrNoData, l.Symbol)
+	}
+
+	return db.pageISIN(ctx, db.NewHTTPClient(DataSourceInvesting), l.URL)
+}
+
+func (db *DB) investingSearch(ctx context.Context, client *retryablehttp.Client, query string) ([]InvestingSearchResponseQuote, error) {
+	invURL, err := url.Parse(investingURL + "/search/service/searchTopBar")
+	if err != nil {
+		return nil, err
+	}
+
 	b := url.Values{
-		"search_text": []string{isin.ID},
+		"search_text": []string{query},
 	}
 
 	db.logger.Debugf("Body: %s", b.Encode())
 
 	req, err := retryablehttp.NewRequest("POST", invURL.String(), []byte(b.Encode()))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Set("User-Agent", "Me")
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := doRequest(withMetaRequest(ctx), client, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var output InvestingSearchResponse
 
 	if err := json.Unmarshal(body, &output); err != nil {
-		return err
-	}
-
-	if len(output.Quotes) == 0 {
-		return nil
+		return nil, err
 	}
 
-	q := output.Quotes[0]
-
-	isin.XID = strconv.Itoa(q.PairID)
-	isin.Name = q.Name
-	isin.AssetClass = q.PairType
-
-	return db.DB().Save(isin)
+	return output.Quotes, nil
 }
 
-func (db *DB) InvestingUpdateValuationsFromHTTP(isin *ISIN, client *retryablehttp.Client) error {
-	sinceTS := "1000000000"
-	curTS := fmt.Sprintf("%d", time.Now().Unix())
-
-	v, err := db.GetValuation(isin.ID)
+func (db *DB) InvestingUpdateValuationsFromHTTP(ctx context.Context, isin *ISIN, client *retryablehttp.Client, since time.Time) error {
+	vals, err := db.investingValuationsSince(ctx, isin, client, since)
 	if err != nil {
-		if !errors.Is(err, storm.ErrNotFound) {
-			return err
-		}
-	} else {
-		sinceTS = fmt.Sprintf("%d", v.Date.Unix())
+		return err
 	}
 
+	return db.ImportValuations(isin, vals)
+}
+
+func (db *DB) investingValuationsSince(ctx context.Context, isin *ISIN, client *retryablehttp.Client, since time.Time) ([]*Valuation, error) {
+	sinceTS := fmt.Sprintf("%d", since.Unix())
+	curTS := fmt.Sprintf("%d", db.Now().Unix())
+
 	invURL, err := url.Parse("https://tvc4.investing.com/1d34c13b0d6656b98005c7e69f95ccf7/" + curTS + "/36/16/16/history")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	query := url.Values{
-		"symbol": []string{isin.XID},
+		"symbol": []string{isin.SourceID(DataSourceInvesting)},
 		"from":   []string{sinceTS},
 		"to":     []string{curTS},
 	}
@@ -143,37 +253,30 @@ func (db *DB) InvestingUpdateValuationsFromHTTP(isin *ISIN, client *retryablehtt
 
 	req, err := retryablehttp.NewRequest("GET", invURL.String(), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Set("User-Agent", "Me")
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := doRequest(ctx, client, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var output InvestingSeries
 
 	if err = json.Unmarshal(body, &output); err != nil {
-		return err
+		return nil, err
 	}
 
 	vals, err := investingToValuaions(isin.ID, output)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	db.logger.Debugf("got %d valuations", len(vals))
 
-	return db.ImportValuations(isin, vals)
+	return vals, nil
 }
 
 func investingToValuaions(isin string, series InvestingSeries) ([]*Valuation, error) { //nolint:unparam
@@ -187,12 +290,13 @@ func investingToValuaions(isin string, series InvestingSeries) ([]*Valuation, er
 		parsed := time.Unix(d, 0)
 
 		result[seq] = &Valuation{
-			ISIN:  isin,
-			Date:  parsed,
-			Open:  series.Opens[seq],
-			High:  series.Highs[seq],
-			Low:   series.Lows[seq],
-			Close: series.Closes[seq],
+			ISIN:   isin,
+			Date:   parsed,
+			Open:   series.Opens[seq],
+			High:   series.Highs[seq],
+			Low:    series.Lows[seq],
+			Close:  series.Closes[seq],
+			Source: DataSourceInvesting,
 		}
 	}
 
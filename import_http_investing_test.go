@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInvestingUpdateFromHTTP(t *testing.T) {
+	db := newReplayDB(t, "testdata/investing")
+	isin := &ISIN{ID: "US0378331005", Source: DataSourceInvesting}
+
+	if err := db.InvestingUpdateFromHTTP(context.Background(), isin); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db.GetISIN(isin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := stored.SourceID(DataSourceInvesting); got != "6408" {
+		t.Errorf("investing.com identifier is '%s', want '6408'", got)
+	}
+
+	if stored.Name != "Apple Inc" || stored.AssetClass != "equities" {
+		t.Errorf("name and asset class are '%s' and '%s', want 'Apple Inc' and 'equities'", stored.Name, stored.AssetClass)
+	}
+
+	want := []Valuation{
+		{Date: time.Unix(1710288000, 0), Open: 172.77, High: 173.19, Low: 170.76, Close: 171.13},
+		{Date: time.Unix(1710374400, 0), Open: 172.91, High: 174.31, Low: 172.05, Close: 173.00},
+		{Date: time.Unix(1710460800, 0), Open: 171.17, High: 172.62, Low: 170.29, Close: 172.62},
+	}
+
+	vals := storedValuations(t, db, isin.ID)
+	if len(vals) != len(want) {
+		t.Fatalf("got %d valuations, want %d", len(vals), len(want))
+	}
+
+	for k, w := range want {
+		v := vals[k]
+
+		if !v.Date.Equal(w.Date) || v.Open != w.Open || v.High != w.High || v.Low != w.Low || v.Close != w.Close || v.Source != DataSourceInvesting {
+			t.Errorf("valuation %d is %+v, want %+v", k, v, w)
+		}
+	}
+}
+
+func TestInvestingListingISIN(t *testing.T) {
+	db := newReplayDB(t, "testdata/investing")
+	l := &Listing{Symbol: "AAPL", URL: investingURL + "/equities/apple-computer-inc", Source: DataSourceInvesting}
+
+	isin, err := db.ListingISIN(context.Background(), l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isin != "US0378331005" {
+		t.Errorf("ISIN is '%s', want 'US0378331005'", isin)
+	}
+}
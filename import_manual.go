@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DataSourceManual = "manual"
+
+var (
+	ErrCSVColumns   = errors.New("unexpected CSV columns")
+	ErrCSVNoISIN    = errors.New("CSV has no ISIN column and no ISIN was given")
+	ErrCSVDelimiter = errors.New("CSV delimiter must be a single character")
+)
+
+// ManualUpdate stores the ISIN; there is nothing to fetch for manual prices
+func (db *DB) ManualUpdate(_ context.Context, isin *ISIN) error {
+	return db.DB().Save(isin)
+}
+
+// SetPrice stores a manual valuation for the ISIN
+func (db *DB) SetPrice(isinID string, date time.Time, value float64) error {
+	defer db.SendAlerts(context.Background())
+
+	isin, err := db.GetISIN(isinID)
+	if err != nil {
+		return err
+	}
+
+	return db.ImportValuations(isin, []*Valuation{{
+		ISIN:   isin.ID,
+		Date:   date,
+		Open:   value,
+		High:   value,
+		Low:    value,
+		Close:  value,
+		Source: DataSourceManual,
+	}})
+}
+
+// ImportPricesCSV loads manual valuations from a CSV file; columns are taken
+// from the header (isin, date, value or open, high, low, close), or default
+// to date,value or date,open,high,low,close without header
+func (db *DB) ImportPricesCSV(file string, isinID string, delimiter rune) error {
+	defer db.SendAlerts(context.Background())
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var (
+		columns map[string]int
+		perISIN = map[string][]*Valuation{}
+		line    = 0
+	)
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		line++
+
+		if columns == nil {
+			if columns = csvHeader(record); columns != nil {
+				continue
+			}
+
+			if columns, err = csvDefaultColumns(len(record)); err != nil {
+				return err
+			}
+		}
+
+		v, err := csvValuation(record, columns, isinID)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		perISIN[v.ISIN] = append(perISIN[v.ISIN], v)
+	}
+
+	for id, vals := range perISIN {
+		isin, err := db.GetISIN(id)
+		if err != nil {
+			return fmt.Errorf("ISIN '%s': %w", id, err)
+		}
+
+		db.logger.Infof("Importing %d prices for '%s'", len(vals), id)
+
+		if err := db.ImportValuations(isin, vals); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// csvHeader returns the column positions when the record is a header
+func csvHeader(record []string) map[string]int {
+	columns := map[string]int{}
+
+	for i, c := range record {
+		c = strings.ToLower(strings.TrimSpace(c))
+
+		switch c {
+		case "isin", "date", "value", "open", "high", "low", "close":
+			columns[c] = i
+		}
+	}
+
+	if _, ok := columns["date"]; !ok {
+		return nil
+	}
+
+	return columns
+}
+
+func csvDefaultColumns(n int) (map[string]int, error) {
+	switch n {
+	case 2:
+		return map[string]int{"date": 0, "value": 1}, nil
+	case 5:
+		return map[string]int{"date": 0, "open": 1, "high": 2, "low": 3, "close": 4}, nil
+	default:
+		return nil, fmt.Errorf("%w: expected 2 or 5 columns, got %d", ErrCSVColumns, n)
+	}
+}
+
+func csvValuation(record []string, columns map[string]int, isinID string) (*Valuation, error) {
+	get := func(name string) (string, bool) {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return "", false
+		}
+
+		return strings.TrimSpace(record[i]), true
+	}
+
+	v := Valuation{
+		ISIN:   isinID,
+		Source: DataSourceManual,
+	}
+
+	if id, ok := get("isin"); ok && id != "" {
+		v.ISIN = id
+	}
+
+	if v.ISIN == "" {
+		return nil, ErrCSVNoISIN
+	}
+
+	d, _ := get("date")
+
+	date, err := time.Parse("2006-01-02", d)
+	if err != nil {
+		return nil, err
+	}
+
+	v.Date = date
+
+	if s, ok := get("value"); ok {
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		v.Open, v.High, v.Low, v.Close = value, value, value, value
+
+		return &v, nil
+	}
+
+	for name, field := range map[string]*float64{"open": &v.Open, "high": &v.High, "low": &v.Low, "close": &v.Close} {
+		s, ok := get(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: missing '%s'", ErrCSVColumns, name)
+		}
+
+		if *field, err = strconv.ParseFloat(s, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	return &v, nil
+}
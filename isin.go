@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -10,11 +11,21 @@ import (
 
 type ISIN struct {
 	ID         string `storm:"id"`
-	XID        string `storm:"index"`
+	XID        string `storm:"index"` // legacy identifier, see migrateXID
 	Name       string
 	AssetClass string
 	Nomination string
+	Exchange   string
 	Source     string
+	Sources    []string
+	SourceIDs  map[string]string
+	PriceField string
+
+	TaxCategory    string
+	BondPercentage float64
+
+	Bond      *BondTerms
+	Benchmark bool
 
 	Shares        float64
 	ValuePerShare float64
@@ -25,28 +36,91 @@ type ISIN struct {
 }
 
 func (i *ISIN) OwnedValue() float64 {
-	return i.Shares * i.ValuePerShare
+	return i.ValueAt(i.ValuePerShare, i.Shares, i.UpdatedAt)
 }
 
-func (db *DB) GetISIN(isin string) (*ISIN, error) {
-	var i ISIN
+// SourceChain returns the sources to fetch data from, in order of preference
+func (i *ISIN) SourceChain() []string {
+	if len(i.Sources) > 0 {
+		return i.Sources
+	}
 
-	query := db.DB().Select(
-		q.Eq("ID", isin),
-	).Limit(1)
+	return []string{i.Source}
+}
 
-	if err := query.First(&i); err != nil {
-		return nil, err
+// SetSourceChain sets the sources to fetch data from; the first one is the
+// primary source
+func (i *ISIN) SetSourceChain(sources []string) {
+	i.migrateXID()
+
+	i.Source = sources[0]
+	i.Sources = sources
+
+	if len(sources) == 1 {
+		i.Sources = nil
 	}
+}
 
-	return &i, nil
+// SourceID returns the identifier of the ISIN at the source
+func (i *ISIN) SourceID(source string) string {
+	return i.SourceIDs[source]
 }
 
-func (db *DB) GetISINByXID(xid string) (*ISIN, error) {
+func (i *ISIN) SetSourceID(source string, id string) {
+	if i.SourceIDs == nil {
+		i.SourceIDs = map[string]string{}
+	}
+
+	i.SourceIDs[source] = id
+}
+
+// migrateXID moves the identifier of databases from before SourceIDs to the
+// source it belonged to, which is the primary source at that time; it returns
+// whether anything changed
+func (i *ISIN) migrateXID() bool {
+	if i.XID == "" {
+		return false
+	}
+
+	if _, ok := i.SourceIDs[i.Source]; !ok {
+		i.SetSourceID(i.Source, i.XID)
+	}
+
+	i.XID = ""
+
+	return true
+}
+
+// migrateXIDs moves the legacy identifiers of all ISINs to their source
+func (db *DB) migrateXIDs() error {
+	var isins []ISIN
+
+	if err := db.DB().Select(q.Not(q.Eq("XID", ""))).Find(&isins); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	for k := range isins {
+		if !isins[k].migrateXID() {
+			continue
+		}
+
+		if err := db.DB().Save(&isins[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) GetISIN(isin string) (*ISIN, error) {
 	var i ISIN
 
 	query := db.DB().Select(
-		q.Eq("XID", xid),
+		q.Eq("ID", isin),
 	).Limit(1)
 
 	if err := query.First(&i); err != nil {
@@ -64,20 +138,157 @@ func (i *ISIN) ISINNomination() string {
 	return i.ID + ":" + i.Nomination
 }
 
-func (db *DB) AddOrUpdateISIN(isinID string, source string) error {
+// ISINOptions are the user provided settings of an ISIN; the sources are
+// only used for new ISINs, other empty settings keep their current value
+type ISINOptions struct {
+	Sources    []string
+	PriceField string
+	Name       string
+	Currency   string
+	Exchange   string
+	SourceIDs  map[string]string
+}
+
+func (db *DB) AddOrUpdateISIN(ctx context.Context, isinID string, opts ISINOptions) error {
+	defer db.SendAlerts(ctx)
+
 	isin, err := db.GetISIN(isinID)
 	if err != nil {
 		if !errors.Is(err, storm.ErrNotFound) {
 			return err
 		}
 
-		isin = &ISIN{
-			ID:     isinID,
-			Source: source,
+		for _, s := range opts.Sources {
+			if err := db.ValidateSource(s); err != nil {
+				return err
+			}
+		}
+
+		isin = &ISIN{ID: isinID}
+		isin.SetSourceChain(opts.Sources)
+	}
+
+	if opts.PriceField != "" {
+		if err := ValidatePriceField(opts.PriceField); err != nil {
+			return err
+		}
+
+		isin.PriceField = opts.PriceField
+	}
+
+	if opts.Name != "" {
+		isin.Name = opts.Name
+	}
+
+	if opts.Currency != "" {
+		isin.Nomination = opts.Currency
+	}
+
+	if opts.Exchange != "" {
+		isin.Exchange = opts.Exchange
+	}
+
+	for source, id := range opts.SourceIDs {
+		isin.SetSourceID(source, id)
+	}
+
+	return db.UpdateFromHTTP(ctx, isin)
+}
+
+// SetSources changes the sources of the ISIN, in order of preference
+func (db *DB) SetSources(isinID string, sources []string) error {
+	for _, s := range sources {
+		if err := db.ValidateSource(s); err != nil {
+			return err
+		}
+	}
+
+	isin, err := db.GetISIN(isinID)
+	if err != nil {
+		return err
+	}
+
+	isin.SetSourceChain(sources)
+
+	return db.DB().Save(isin)
+}
+
+// PriceField returns the field of the valuations used as the price of the ISIN
+func (db *DB) PriceField(isin *ISIN) string {
+	if isin.PriceField != "" {
+		return isin.PriceField
+	}
+
+	return db.config.PriceField
+}
+
+// ValueOf returns the price of the ISIN according to the valuation
+func (db *DB) ValueOf(isin *ISIN, v *Valuation) float64 {
+	return v.Value(db.PriceField(isin))
+}
+
+// SetPriceField changes the price field of the ISIN (empty to use the global
+// setting) and recalculates its value per share
+func (db *DB) SetPriceField(isinID string, field string) error {
+	if field != "" {
+		if err := ValidatePriceField(field); err != nil {
+			return err
 		}
 	}
 
-	return db.UpdateFromHTTP(isin)
+	isin, err := db.GetISIN(isinID)
+	if err != nil {
+		return err
+	}
+
+	isin.PriceField = field
+
+	if err := db.DB().Save(isin); err != nil {
+		return err
+	}
+
+	return db.RefreshValuePerShare(isin)
+}
+
+// RefreshValuePerShare recalculates the value per share from the last valuation
+func (db *DB) RefreshValuePerShare(isin *ISIN) error {
+	v, err := db.GetValuation(isin.ID)
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	value := db.ValueOf(isin, v)
+	if value == isin.ValuePerShare {
+		return nil
+	}
+
+	db.logger.Infof("Value per share for '%s' changed from %.2f to %.2f (%s)", isin.ID, isin.ValuePerShare, value, db.PriceField(isin))
+
+	isin.ValuePerShare = value
+
+	return db.DB().Save(isin)
+}
+
+// currentValuePerShare sets the value per share of the ISIN from its last
+// valuation with the price field in effect now, which may differ from the one
+// it was stored with
+func (db *DB) currentValuePerShare(isin *ISIN) error {
+	v, err := db.GetValuation(isin.ID)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	isin.ValuePerShare = db.ValueOf(isin, v)
+
+	return nil
 }
 
 func (db *DB) GetAllISIN() ([]ISIN, error) {
@@ -97,25 +308,20 @@ func (db *DB) UpdateShares(isinID string) error {
 	db.logger.Debugf("Calculating shares for ISIN: %s (%.2f)", isin.ID, isin.Shares)
 
 	transactions, err := db.GetTransactionsForISIN(isinID)
-	if err != nil {
-		if errors.Is(err, storm.ErrNotFound) {
-			return nil
-		}
-
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
 		return err
 	}
 
-	newValue := 0.0
-
-	for _, t := range transactions {
-		newValue += t.TotalShares
+	newValue, err := db.sharesAt(isin.ID, transactions, endOfTime)
+	if err != nil {
+		return err
 	}
 
 	if isin.Shares == newValue {
 		return nil
 	}
 
-	db.logger.Infof("Amount of shares for '%s' changed from %.2f to %.2f", isin.ID, isin.Shares, newValue)
+	db.logger.Infof("Amount of shares for '%s' changed from %s to %s", isin.ID, formatShares(isin.ID, isin.Shares, 2), formatShares(isin.ID, newValue, 2))
 
 	isin.Shares = newValue
 
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+)
+
+const (
+	LotMethodFIFO = "fifo"
+	LotMethodLIFO = "lifo"
+	LotMethodHIFO = "hifo"
+)
+
+var (
+	ErrUnknownLotMethod = errors.New("unknown lot matching method")
+
+	LotMethods = []string{LotMethodFIFO, LotMethodLIFO, LotMethodHIFO}
+)
+
+func ValidateLotMethod(method string) error {
+	for _, m := range LotMethods {
+		if m == method {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: '%s' (expected one of %s)", ErrUnknownLotMethod, method, strings.Join(LotMethods, ", "))
+}
+
+// Lot is a number of shares bought in one transaction and still held; shares
+// are in current units, the cost includes the fee of the purchase
+type Lot struct {
+	ISIN     string
+	Acquired time.Time
+	Shares   float64
+	Cost     float64
+}
+
+// Disposal is the sale of (part of) a lot; the proceeds are after the fee of
+// the sale, which is spread over the lots by their number of shares. Acquired
+// is zero when no lot matched the sale
+type Disposal struct {
+	ISIN     string
+	Acquired time.Time
+	Disposed time.Time
+	Shares   float64
+	Proceeds float64
+	Cost     float64
+}
+
+func (d *Disposal) Gain() float64 {
+	return d.Proceeds - d.Cost
+}
+
+// HoldingDays returns the number of days the shares were held
+func (d *Disposal) HoldingDays() int {
+	return int(d.Disposed.Sub(d.Acquired).Hours() / 24)
+}
+
+// acquisition returns the acquisition date and the holding days to display,
+// both empty when no lot matched the sale
+func (d *Disposal) acquisition() (string, string) {
+	if d.Acquired.IsZero() {
+		return "", ""
+	}
+
+	return timeToDate(&d.Acquired), strconv.Itoa(d.HoldingDays())
+}
+
+// nextLot returns the index of the lot to sell from: the oldest (fifo), the
+// newest (lifo), or the one with the highest cost per share (hifo)
+func nextLot(lots []Lot, method string) int {
+	switch method {
+	case LotMethodLIFO:
+		return len(lots) - 1
+	case LotMethodHIFO:
+		best := 0
+
+		for k, l := range lots {
+			if l.Cost/l.Shares > lots[best].Cost/lots[best].Shares {
+				best = k
+			}
+		}
+
+		return best
+	default:
+		return 0
+	}
+}
+
+// MatchLots matches the sales of the ISIN with the lots bought before, using
+// the method, and returns the disposals and the lots still held. The lots of
+// an ISIN merged into this one move over on the date of the merger, with their
+// cost and acquisition date. Shares sold without a matching lot have no cost
+func (db *DB) MatchLots(isin string, method string) ([]Disposal, []Lot, error) {
+	return db.matchLots(isin, method, endOfTime)
+}
+
+// matchLots matches the lots with the transactions up to the date
+func (db *DB) matchLots(isin string, method string, until time.Time) ([]Disposal, []Lot, error) {
+	transactions, err := db.GetTransactionsForISIN(isin)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	actions, err := db.corporateActions("ISIN", isin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged, gone := mergedAway(actions, until)
+	if gone {
+		until = merged.Add(-time.Nanosecond)
+	}
+
+	mergers, err := db.mergersInto(isin, until)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Within a day, buys come first so a day trade sells the shares it bought
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if transactions[i].Date.Equal(transactions[j].Date) {
+			return transactions[i].TotalShares > 0 && transactions[j].TotalShares <= 0
+		}
+
+		return transactions[i].Date.Before(transactions[j].Date)
+	})
+
+	var (
+		disposals []Disposal
+		lots      []Lot
+	)
+
+	join := func(m *CorporateAction) error {
+		_, old, err := db.matchLots(m.ISIN, method, m.Date.Add(-time.Nanosecond))
+		if err != nil {
+			return err
+		}
+
+		f := m.Ratio * splitFactor(actions, m.Date)
+
+		for _, l := range old {
+			l.ISIN = isin
+			l.Shares *= f
+			lots = append(lots, l)
+		}
+
+		// Keep the lots in order of acquisition for fifo and lifo
+		sort.SliceStable(lots, func(i, j int) bool {
+			return lots[i].Acquired.Before(lots[j].Acquired)
+		})
+
+		return nil
+	}
+
+	for _, t := range transactions {
+		if t.Date.After(until) {
+			break
+		}
+
+		for ; len(mergers) > 0 && !t.Date.Before(mergers[0].Date); mergers = mergers[1:] {
+			if err := join(&mergers[0]); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if t.IsDividend() {
+			continue
+		}
+
+		shares := t.TotalShares * splitFactor(actions, t.Date)
+		value := math.Abs(t.TotalValue)
+
+		if shares > 0 {
+			lots = append(lots, Lot{ISIN: isin, Acquired: t.Date, Shares: shares, Cost: value + t.Fee})
+			continue
+		}
+
+		value -= t.Fee
+
+		remaining := -shares
+
+		for remaining > 0 {
+			d := Disposal{ISIN: isin, Disposed: t.Date, Shares: remaining}
+
+			if len(lots) > 0 {
+				k := nextLot(lots, method)
+				l := &lots[k]
+
+				d.Acquired = l.Acquired
+				d.Shares = math.Min(remaining, l.Shares)
+				d.Cost = l.Cost * d.Shares / l.Shares
+
+				l.Cost -= d.Cost
+				l.Shares -= d.Shares
+
+				if l.Shares <= 1e-9 {
+					lots = append(lots[:k], lots[k+1:]...)
+				}
+			} else {
+				db.logger.Warnf("Sale of '%s' at %s exceeds the shares bought, assuming no cost", isin, timeToDate(&t.Date))
+			}
+
+			d.Proceeds = value * d.Shares / -shares
+			remaining -= d.Shares
+
+			disposals = append(disposals, d)
+		}
+	}
+
+	for k := range mergers {
+		if err := join(&mergers[k]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if gone {
+		// The lots moved to the ISIN it merged into
+		lots = nil
+	}
+
+	return disposals, lots, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchLots(t *testing.T) {
+	type trade struct {
+		day           int
+		shares, value float64
+		fee           float64
+	}
+
+	day := func(d int) time.Time {
+		if d == 0 {
+			return time.Time{}
+		}
+
+		return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		trades    []trade
+		disposals []Disposal
+		lots      []Lot
+	}{
+		{
+			name:   "fifo across lots",
+			method: LotMethodFIFO,
+			trades: []trade{{1, 10, 1000, 0}, {2, 10, 2000, 0}, {3, -15, -4500, 0}},
+			disposals: []Disposal{
+				{Acquired: day(1), Disposed: day(3), Shares: 10, Proceeds: 3000, Cost: 1000},
+				{Acquired: day(2), Disposed: day(3), Shares: 5, Proceeds: 1500, Cost: 1000},
+			},
+			lots: []Lot{{Acquired: day(2), Shares: 5, Cost: 1000}},
+		},
+		{
+			name:   "lifo across lots",
+			method: LotMethodLIFO,
+			trades: []trade{{1, 10, 1000, 0}, {2, 10, 2000, 0}, {3, -15, -4500, 0}},
+			disposals: []Disposal{
+				{Acquired: day(2), Disposed: day(3), Shares: 10, Proceeds: 3000, Cost: 2000},
+				{Acquired: day(1), Disposed: day(3), Shares: 5, Proceeds: 1500, Cost: 500},
+			},
+			lots: []Lot{{Acquired: day(1), Shares: 5, Cost: 500}},
+		},
+		{
+			name:   "hifo across lots",
+			method: LotMethodHIFO,
+			trades: []trade{{1, 10, 1000, 0}, {2, 10, 3000, 0}, {3, 10, 2000, 0}, {4, -15, -4500, 0}},
+			disposals: []Disposal{
+				{Acquired: day(2), Disposed: day(4), Shares: 10, Proceeds: 3000, Cost: 3000},
+				{Acquired: day(3), Disposed: day(4), Shares: 5, Proceeds: 1500, Cost: 1000},
+			},
+			lots: []Lot{{Acquired: day(1), Shares: 10, Cost: 1000}, {Acquired: day(3), Shares: 5, Cost: 1000}},
+		},
+		{
+			name:   "partial sells of one lot",
+			method: LotMethodFIFO,
+			trades: []trade{{1, 10, 1000, 0}, {2, -4, -480, 0}, {3, -4, -520, 0}},
+			disposals: []Disposal{
+				{Acquired: day(1), Disposed: day(2), Shares: 4, Proceeds: 480, Cost: 400},
+				{Acquired: day(1), Disposed: day(3), Shares: 4, Proceeds: 520, Cost: 400},
+			},
+			lots: []Lot{{Acquired: day(1), Shares: 2, Cost: 200}},
+		},
+		{
+			name:   "proportional fees",
+			method: LotMethodFIFO,
+			trades: []trade{{1, 10, 1000, 10}, {2, 30, 3000, 20}, {3, -20, -3000, 30}},
+			disposals: []Disposal{
+				{Acquired: day(1), Disposed: day(3), Shares: 10, Proceeds: 1485, Cost: 1010},
+				{Acquired: day(2), Disposed: day(3), Shares: 10, Proceeds: 1485, Cost: 3020 / 3.0},
+			},
+			lots: []Lot{{Acquired: day(2), Shares: 20, Cost: 6040 / 3.0}},
+		},
+		{
+			name:   "same day buy before sell",
+			method: LotMethodLIFO,
+			trades: []trade{{1, 10, 1000, 0}, {2, -5, -600, 0}, {2, 5, 550, 0}},
+			disposals: []Disposal{
+				{Acquired: day(2), Disposed: day(2), Shares: 5, Proceeds: 600, Cost: 550},
+			},
+			lots: []Lot{{Acquired: day(1), Shares: 10, Cost: 1000}},
+		},
+		{
+			name:   "sale beyond the lots",
+			method: LotMethodFIFO,
+			trades: []trade{{1, 5, 500, 0}, {2, -10, -1200, 0}},
+			disposals: []Disposal{
+				{Acquired: day(1), Disposed: day(2), Shares: 5, Proceeds: 600, Cost: 500},
+				{Disposed: day(2), Shares: 5, Proceeds: 600},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		db := newTestDB(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC))
+
+		if err := db.DB().Save(&ISIN{ID: "BE0000000001"}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tr := range tt.trades {
+			tx := Transaction{
+				ISIN: "BE0000000001", Type: TransactionTypeTrade, Date: day(tr.day),
+				TotalShares: tr.shares, TotalValue: tr.value, Fee: tr.fee,
+			}
+
+			if err := db.CreateTransaction(&tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		disposals, lots, err := db.MatchLots("BE0000000001", tt.method)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(disposals) != len(tt.disposals) {
+			t.Errorf("%s: got disposals %+v, want %+v", tt.name, disposals, tt.disposals)
+		} else {
+			for k, w := range tt.disposals {
+				d := disposals[k]
+
+				if !d.Acquired.Equal(w.Acquired) || !d.Disposed.Equal(w.Disposed) || !near(d.Shares, w.Shares) ||
+					!near(d.Proceeds, w.Proceeds) || !near(d.Cost, w.Cost) {
+					t.Errorf("%s: disposal %d is %+v, want %+v", tt.name, k, d, w)
+				}
+			}
+		}
+
+		if len(lots) != len(tt.lots) {
+			t.Errorf("%s: got lots %+v, want %+v", tt.name, lots, tt.lots)
+		} else {
+			for k, w := range tt.lots {
+				l := lots[k]
+
+				if !l.Acquired.Equal(w.Acquired) || !near(l.Shares, w.Shares) || !near(l.Cost, w.Cost) {
+					t.Errorf("%s: lot %d is %+v, want %+v", tt.name, k, l, w)
+				}
+			}
+		}
+	}
+}
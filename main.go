@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/mitchellh/go-homedir"
 	"github.com/sirupsen/logrus"
 )
 
 type App struct {
 	Debug bool
 
-	db       *DB
-	logger   *logrus.Logger
-	currency *Currency
+	configFile string
+	dbFile     string
+	locale     string
+	httpRecord string
+	httpReplay string
+	timeout    time.Duration
+	config     *Config
+	db         *DB
+	logger     *logrus.Logger
+	currency   *Currency
 }
 
 func (a *App) DB() *DB {
@@ -24,41 +33,93 @@ func (a *App) Logger() *logrus.Logger {
 	return a.logger
 }
 
-func main() {
-	DBFile := ""
+// OpenDB opens the database for reading and writing; it is kept open until
+// the application exits
+func (a *App) OpenDB() error {
+	a.db = NewDB(a.config, a.logger)
 
-	if os.Getenv("FINTRK_DBFILE") != "" {
-		DBFile = os.Getenv("FINTRK_DBFILE")
-	}
+	// Recording into a directory with older fixtures runs at their clock
+	for _, dir := range []string{a.config.HTTPReplayDir, a.config.HTTPRecordDir} {
+		if dir == "" {
+			continue
+		}
 
-	if DBFile == "" {
-		d, err := homedir.Dir()
+		t, err := ReplayClock(dir)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
-		DBFile = filepath.Join(d, ".fintrk.db")
+		a.db.PinClock(t)
+	}
+
+	return a.db.Initialize()
+}
+
+// OpenDBReadOnly opens a separate read-only handle to the database; it takes
+// a shared lock, so it waits (and fails after a timeout) while another process
+// has the file open for writing, like a running update. Use the daemon with
+// --metrics-listen to serve metrics during updates
+func (a *App) OpenDBReadOnly() (*DB, error) {
+	db := NewDB(a.config, a.logger)
+
+	if err := db.InitializeReadOnly(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (a *App) Close() {
+	if a.db == nil || a.db.DB() == nil {
+		return
+	}
+
+	a.db.Close()
+}
+
+// UpdateContext returns a context for fetching data, limited by the overall
+// update timeout
+func (a *App) UpdateContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if a.config.UpdateTimeout > 0 {
+		return context.WithTimeout(parent, a.config.UpdateTimeout)
 	}
 
+	return context.WithCancel(parent)
+}
+
+// TableFormat returns the requested format, or the configured default
+func (a *App) TableFormat(f string) string {
+	if f != "" {
+		return f
+	}
+
+	return a.config.Format
+}
+
+// defaultSource returns the source for a new identifier without sources
+func (a *App) defaultSource(id string) string {
+	if IsCrypto(id) {
+		return DataSourceCoinGecko
+	}
+
+	return a.config.DefaultSource
+}
+
+func main() {
 	l := logrus.StandardLogger()
 	app := App{
-		db:       NewDB(DBFile, l),
+		config:   DefaultConfig(),
 		logger:   l,
 		currency: &Currency{},
 	}
 
-	if err := app.currency.Initialize(); err != nil {
-		app.logger.Fatal(err)
-	}
-
-	if err := app.DB().Initialize(); err != nil {
-		app.logger.Fatalf("%v", err)
-	}
+	defer app.Close()
 
-	defer app.DB().Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	cmd := app.RootCmd()
-	if err := cmd.Execute(); err != nil {
+	if err := cmd.ExecuteContext(ctx); err != nil {
 		app.logger.Fatal(err)
 	}
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metric struct {
+	name   string
+	help   string
+	kind   string
+	values []metricValue
+}
+
+type metricValue struct {
+	labels [][2]string
+	value  float64
+}
+
+func (m *metric) add(value float64, labels ...[2]string) {
+	m.values = append(m.values, metricValue{labels: labels, value: value})
+}
+
+func (m *metric) write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+		return err
+	}
+
+	for _, v := range m.values {
+		labels := make([]string, len(v.labels))
+
+		for i, l := range v.labels {
+			labels[i] = fmt.Sprintf(`%s="%s"`, l[0], metricsLabelEscaper.Replace(l[1]))
+		}
+
+		if _, err := fmt.Fprintf(w, "%s{%s} %v\n", m.name, strings.Join(labels, ","), v.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func label(name, value string) [2]string {
+	return [2]string{name, value}
+}
+
+// WriteMetrics renders the current state of all tracked funds and the update
+// counters in the Prometheus text exposition format
+func (db *DB) WriteMetrics(w io.Writer) error {
+	isins, err := db.GetAllISIN()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(isins, func(i, j int) bool {
+		return isins[i].ID < isins[j].ID
+	})
+
+	for k := range isins {
+		if err := db.currentValuePerShare(&isins[k]); err != nil {
+			return err
+		}
+	}
+
+	stats, err := db.GetAllSourceStats()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Source < stats[j].Source
+	})
+
+	valuePerShare := &metric{name: "fintrk_value_per_share", help: "Last known value per share.", kind: "gauge"}
+	shares := &metric{name: "fintrk_shares", help: "Amount of shares owned.", kind: "gauge"}
+	ownedValue := &metric{name: "fintrk_owned_value", help: "Value of the owned shares.", kind: "gauge"}
+	updateAge := &metric{name: "fintrk_last_update_age_seconds", help: "Age of the last known value in seconds.", kind: "gauge"}
+	runs := &metric{name: "fintrk_update_runs_total", help: "Number of update runs per source and result.", kind: "counter"}
+
+	now := time.Now()
+
+	for _, i := range isins {
+		labels := [][2]string{
+			label("isin", i.ID),
+			label("name", i.Name),
+			label("nomination", i.Nomination),
+			label("asset_class", i.AssetClass),
+			label("source", i.Source),
+		}
+
+		valuePerShare.add(i.ValuePerShare, labels...)
+		shares.add(i.Shares, labels...)
+		ownedValue.add(i.OwnedValue(), labels...)
+
+		if !i.UpdatedAt.IsZero() {
+			updateAge.add(now.Sub(i.UpdatedAt).Seconds(), labels...)
+		}
+	}
+
+	for _, s := range stats {
+		runs.add(float64(s.Success), label("source", s.Source), label("result", "success"))
+		runs.add(float64(s.Failure), label("source", s.Source), label("result", "failure"))
+	}
+
+	for _, m := range []*metric{valuePerShare, shares, ownedValue, updateAge, runs} {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MetricsHandler serves the metrics, reading them from the database returned
+// by open; release is called when the database is no longer needed. When the
+// database can not be opened, for example while another process holds it, the
+// last metrics served are repeated
+func (a *App) MetricsHandler(open func() (*DB, error), release func(*DB)) http.Handler {
+	var (
+		lastLock sync.Mutex
+		last     []byte
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+
+		db, err := open()
+		if err == nil {
+			err = db.WriteMetrics(&buf)
+			release(db)
+		}
+
+		lastLock.Lock()
+		defer lastLock.Unlock()
+
+		switch {
+		case err == nil:
+			last = buf.Bytes()
+		case last != nil:
+			a.Logger().Warnf("Error reading metrics, serving the previous ones: %v", err)
+		default:
+			a.Logger().Errorf("Error reading metrics: %v", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", metricsContentType)
+
+		if _, err := w.Write(last); err != nil {
+			a.Logger().Errorf("Error writing metrics: %v", err)
+		}
+	})
+}
+
+func (a *App) ServeMetrics(listen string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", a.MetricsHandler(a.OpenDBReadOnly, func(db *DB) { db.Close() }))
+
+	a.Logger().Infof("Serving metrics on %s/metrics", listen)
+
+	return http.ListenAndServe(listen, mux) //nolint:gosec
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+var ErrUnknownInterval = errors.New("unknown interval")
+
+// intervalDate returns the date n intervals (day, week, month or year) after
+// the start; every step counts from the start, so monthly steps from January
+// 31 go to the end of February and then to March 31
+func intervalDate(since time.Time, interval string, n int) (time.Time, error) {
+	switch interval {
+	case "day":
+		return since.AddDate(0, 0, n), nil
+	case "week":
+		return since.AddDate(0, 0, 7*n), nil
+	case "month":
+		return addMonths(since, n), nil
+	case "year":
+		return addMonths(since, 12*n), nil
+	default:
+		return since, fmt.Errorf("%w: '%s' (expected day, week, month or year)", ErrUnknownInterval, interval)
+	}
+}
+
+// ShowBalanceSheet prints every security, cash account, asset and liability
+// at the date, and the net worth in the base currency
+func (a *App) ShowBalanceSheet(tableFormat string, d time.Time) error {
+	items, err := a.DB().BalanceSheet(d)
+	if err != nil {
+		return err
+	}
+
+	base := a.config.BaseCurrency
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Type", "Name", "Nom", "Last update", "Value", "Value (" + base + ")"})
+	configureRenderer(table, tableFormat)
+
+	var total float64
+
+	for _, i := range items {
+		date := ""
+		if !i.Date.IsZero() {
+			date = timeToDate(&i.Date)
+		}
+
+		table.Append([]string{i.Kind, i.Name, i.Currency, date, a.localize(i.Currency, i.Value), a.localize(base, i.Base)})
+
+		total += i.Base
+	}
+
+	table.Append([]string{"Net worth", "", base, timeToDate(&d), "", a.localize(base, total)})
+	table.Render()
+
+	return nil
+}
+
+// ShowNetWorthHistory prints the net worth in the base currency at every
+// interval from the start date until now
+func (a *App) ShowNetWorthHistory(tableFormat string, since time.Time, interval string) error {
+	if _, err := intervalDate(since, interval, 1); err != nil {
+		return err
+	}
+
+	base := a.config.BaseCurrency
+	now := a.DB().Now()
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Date", "Securities", "Cash", "Assets", "Liabilities", "Net worth", "Change"})
+	configureRenderer(table, tableFormat)
+
+	var previous *NetWorth
+
+	for step, d := 1, since; ; step++ {
+		if d.After(now) {
+			d = now
+		}
+
+		n, err := a.DB().NetWorthAt(d)
+		if err != nil {
+			return err
+		}
+
+		change := ""
+		if previous != nil {
+			change = a.localize(base, n.Net()-previous.Net())
+		}
+
+		table.Append([]string{
+			timeToDate(&n.Date), a.localize(base, n.Securities), a.localize(base, n.Cash),
+			a.localize(base, n.Assets), a.localize(base, n.Liabilities), a.localize(base, n.Net()), change,
+		})
+
+		previous = n
+
+		if !d.Before(now) {
+			break
+		}
+
+		if d, err = intervalDate(since, interval, step); err != nil {
+			return err
+		}
+	}
+
+	table.Render()
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervalDate(t *testing.T) {
+	jan31 := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		since    time.Time
+		interval string
+		n        int
+		want     time.Time
+	}{
+		{jan31, "day", 1, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{jan31, "week", 2, time.Date(2024, 2, 14, 0, 0, 0, 0, time.UTC)},
+		{jan31, "month", 1, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{jan31, "month", 2, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{jan31, "month", 3, time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)},
+		{jan31, "month", 13, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), "year", 1, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), "year", 4, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := intervalDate(tt.since, tt.interval, tt.n)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("%d %s after %s is %s (%v), want %s", tt.n, tt.interval, timeToDate(&tt.since), timeToDate(&got), err, timeToDate(&tt.want))
+		}
+	}
+
+	if _, err := intervalDate(jan31, "fortnight", 1); err == nil {
+		t.Error("unknown interval accepted")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	NotifierWebhook = "webhook"
+	NotifierEmail   = "email"
+	NotifierCommand = "command"
+)
+
+var (
+	ErrUnknownNotifier = errors.New("unknown notifier")
+	ErrMissingTarget   = errors.New("notifier needs a target")
+	ErrSMTPNotSet      = errors.New("SMTP server not configured")
+	ErrWebhookStatus   = errors.New("webhook returned an error")
+)
+
+type Notifier interface {
+	Notify(ctx context.Context, a *Alert) error
+}
+
+// SMTPConfig holds the mail server used by the email notifier
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+func newNotifier(kind, target string, config *Config) (Notifier, error) {
+	if target == "" {
+		return nil, ErrMissingTarget
+	}
+
+	switch kind {
+	case NotifierWebhook:
+		return &WebhookNotifier{URL: target, HTTP: config.SourceHTTP(NotifierWebhook)}, nil
+	case NotifierEmail:
+		return &EmailNotifier{To: target, SMTP: config.SMTP}, nil
+	case NotifierCommand:
+		return &CommandNotifier{Command: target}, nil
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownNotifier, kind)
+	}
+}
+
+type alertPayload struct {
+	Rule      string    `json:"rule"`
+	ISIN      string    `json:"isin,omitempty"`
+	Condition string    `json:"condition"`
+	Threshold float64   `json:"threshold"`
+	Subject   string    `json:"subject"`
+	Value     float64   `json:"value"`
+	Previous  float64   `json:"previous"`
+	Date      time.Time `json:"date"`
+	Message   string    `json:"message"`
+}
+
+func (a *Alert) payload() alertPayload {
+	return alertPayload{
+		Rule:      a.Rule.UUID.String(),
+		ISIN:      a.Rule.ISIN,
+		Condition: a.Rule.Condition,
+		Threshold: a.Rule.Threshold,
+		Subject:   a.Subject,
+		Value:     a.Value,
+		Previous:  a.Previous,
+		Date:      a.Date,
+		Message:   a.Message(),
+	}
+}
+
+// WebhookNotifier posts the alert as JSON to a URL
+type WebhookNotifier struct {
+	URL  string
+	HTTP HTTPConfig
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, a *Alert) error {
+	j, err := json.Marshal(a.payload())
+	if err != nil {
+		return err
+	}
+
+	req, err := retryablehttp.NewRequest("POST", n.URL, j)
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := newHTTPClient(n.HTTP, nil)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s", ErrWebhookStatus, resp.Status)
+	}
+
+	return nil
+}
+
+// EmailNotifier sends the alert as a plain text mail
+type EmailNotifier struct {
+	To   string
+	SMTP SMTPConfig
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, a *Alert) error {
+	if n.SMTP.Host == "" || n.SMTP.From == "" {
+		return ErrSMTPNotSet
+	}
+
+	// net/smtp can not be cancelled, so only check before sending
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+
+	if n.SMTP.Username != "" {
+		host, _, err := net.SplitHostPort(n.SMTP.Host)
+		if err != nil {
+			return err
+		}
+
+		auth = smtp.PlainAuth("", n.SMTP.Username, n.SMTP.Password, host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + n.SMTP.From,
+		"To: " + n.To,
+		"Subject: fintrk alert: " + a.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		a.Message(),
+		"",
+	}, "\r\n")
+
+	return smtp.SendMail(n.SMTP.Host, auth, n.SMTP.From, strings.Split(n.To, ","), []byte(msg))
+}
+
+// CommandNotifier runs a shell command, with the alert as JSON on stdin and
+// its main properties in FINTRK_ALERT_* environment variables
+type CommandNotifier struct {
+	Command string
+}
+
+func (n *CommandNotifier) Notify(ctx context.Context, a *Alert) error {
+	j, err := json.Marshal(a.payload())
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.Command) //nolint:gosec
+	cmd.Stdin = bytes.NewReader(j)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"FINTRK_ALERT_RULE="+a.Rule.UUID.String(),
+		"FINTRK_ALERT_ISIN="+a.Rule.ISIN,
+		"FINTRK_ALERT_SUBJECT="+a.Subject,
+		"FINTRK_ALERT_MESSAGE="+a.Message(),
+		fmt.Sprintf("FINTRK_ALERT_VALUE=%f", a.Value),
+		fmt.Sprintf("FINTRK_ALERT_PREVIOUS=%f", a.Previous),
+	)
+
+	return cmd.Run()
+}
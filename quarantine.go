@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+)
+
+// QuarantinedValuation is a valuation that failed validation; it is kept out
+// of the prices until it is accepted, and stays hidden once dropped
+type QuarantinedValuation struct {
+	ID        string `storm:"id"`
+	ISIN      string `storm:"index"`
+	Valuation Valuation
+	Reason    string
+	Detected  time.Time
+	Dropped   bool
+}
+
+// levelShiftPrices is the number of consecutive quarantined prices that have
+// to agree before they are taken as the new level of the ISIN
+const levelShiftPrices = 3
+
+var ErrQuarantineNotFound = errors.New("no quarantined valuation")
+
+// validateValuations moves suspicious valuations into quarantine and returns
+// the others; every price is compared to the previous accepted one, so a
+// single bad price does not poison the next days. When the prices since the
+// previous accepted one were all quarantined but agree with each other, the
+// level shifted and they are accepted together. Dates that already have a
+// price are left to the conflict detection
+func (db *DB) validateValuations(isin *ISIN, valuations []*Valuation) ([]*Valuation, error) {
+	if len(valuations) == 0 {
+		return valuations, nil
+	}
+
+	sorted := make([]*Valuation, len(valuations))
+	copy(sorted, valuations)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	prev, err := db.GetValuationBefore(isin.ID, sorted[0].Date)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	var accepted []*Valuation
+
+	for _, val := range sorted {
+		val.UpdateID()
+
+		var stored Valuation
+
+		err := db.DB().One("ID", val.ID, &stored)
+		if err == nil {
+			accepted = append(accepted, val)
+
+			if err := db.adjustValuation(&stored); err != nil {
+				return nil, err
+			}
+
+			prev = &stored
+
+			continue
+		}
+
+		if !errors.Is(err, storm.ErrNotFound) {
+			return nil, err
+		}
+
+		// Compare in current units, so splits do not look like crashes
+		adjusted := *val
+		if err := db.adjustValuation(&adjusted); err != nil {
+			return nil, err
+		}
+
+		reason := db.suspiciousValuation(isin, prev, &adjusted)
+		if reason == "" {
+			accepted = append(accepted, val)
+			prev = &adjusted
+
+			continue
+		}
+
+		shifted, err := db.levelShift(isin, prev, &adjusted)
+		if err != nil {
+			return nil, err
+		}
+
+		if shifted != nil {
+			db.logger.Infof("Accepting new price level of '%s' at %s: %s", isin.ID, timeToDate(&val.Date), reason)
+
+			accepted = append(append(accepted, shifted...), val)
+			prev = &adjusted
+
+			continue
+		}
+
+		if err := db.quarantine(isin, val, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	return accepted, nil
+}
+
+// levelShift returns the valuations quarantined since the previous accepted
+// one and releases them, when they and the new valuation make up enough
+// consecutive prices that agree with each other
+func (db *DB) levelShift(isin *ISIN, prev *Valuation, val *Valuation) ([]*Valuation, error) {
+	if prev == nil || db.config.MaxChange <= 0 {
+		return nil, nil
+	}
+
+	var all []QuarantinedValuation
+
+	err := db.DB().Find("ISIN", isin.ID, &all)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	var run []QuarantinedValuation
+
+	for _, qv := range all {
+		if !qv.Dropped && qv.Valuation.Date.After(prev.Date) && qv.Valuation.Date.Before(val.Date) {
+			run = append(run, qv)
+		}
+	}
+
+	if len(run)+1 < levelShiftPrices {
+		return nil, nil
+	}
+
+	for k := range run {
+		other := run[k].Valuation
+		if err := db.adjustValuation(&other); err != nil {
+			return nil, err
+		}
+
+		if db.suspiciousValuation(isin, &other, val) != "" {
+			return nil, nil
+		}
+	}
+
+	result := make([]*Valuation, len(run))
+
+	for k := range run {
+		if err := db.DB().DeleteStruct(&run[k]); err != nil {
+			return nil, err
+		}
+
+		v := run[k].Valuation
+		result[k] = &v
+	}
+
+	return result, nil
+}
+
+// suspiciousValuation returns why the valuation looks wrong, or an empty
+// string; large changes in manual prices are trusted
+func (db *DB) suspiciousValuation(isin *ISIN, prev *Valuation, val *Valuation) string {
+	value := db.ValueOf(isin, val)
+	if value <= 0 {
+		return fmt.Sprintf("non-positive price %.4f", value)
+	}
+
+	if prev == nil || val.Source == DataSourceManual || db.config.MaxChange <= 0 {
+		return ""
+	}
+
+	prevValue := db.ValueOf(isin, prev)
+	if prevValue <= 0 {
+		return ""
+	}
+
+	change := math.Abs(value-prevValue) / prevValue * 100
+	if change <= db.config.MaxChange {
+		return ""
+	}
+
+	return fmt.Sprintf("%.2f%% change since %s (%.4f)", change, timeToDate(&prev.Date), prevValue)
+}
+
+func (db *DB) quarantine(isin *ISIN, val *Valuation, reason string) error {
+	qv := QuarantinedValuation{
+		ID:        val.ID + "@" + val.Source,
+		ISIN:      isin.ID,
+		Valuation: *val,
+		Reason:    reason,
+		Detected:  time.Now(),
+	}
+
+	var existing QuarantinedValuation
+
+	err := db.DB().One("ID", qv.ID, &existing)
+	if err == nil {
+		qv.Detected = existing.Detected
+		qv.Dropped = existing.Dropped
+	} else if !errors.Is(err, storm.ErrNotFound) {
+		return err
+	} else {
+		db.logger.Warnf("Quarantined price of '%s' at %s from %s: %s", isin.ID, timeToDate(&val.Date), val.Source, reason)
+	}
+
+	return db.DB().Save(&qv)
+}
+
+// GetQuarantine returns the quarantined valuations that were not dropped
+func (db *DB) GetQuarantine() ([]QuarantinedValuation, error) {
+	var all []QuarantinedValuation
+
+	if err := db.DB().All(&all); err != nil {
+		return nil, err
+	}
+
+	var result []QuarantinedValuation
+
+	for _, qv := range all {
+		if !qv.Dropped {
+			result = append(result, qv)
+		}
+	}
+
+	return result, nil
+}
+
+func (db *DB) getQuarantined(id string) (*QuarantinedValuation, error) {
+	var qv QuarantinedValuation
+
+	err := db.DB().One("ID", id, &qv)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fmt.Errorf("%w: '%s'", ErrQuarantineNotFound, id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &qv, nil
+}
+
+// AcceptQuarantined imports the quarantined valuation without validating it
+func (db *DB) AcceptQuarantined(id string) error {
+	qv, err := db.getQuarantined(id)
+	if err != nil {
+		return err
+	}
+
+	isin, err := db.GetISIN(qv.ISIN)
+	if err != nil {
+		return err
+	}
+
+	if err := db.importValuations(isin, []*Valuation{&qv.Valuation}); err != nil {
+		return err
+	}
+
+	return db.DB().DeleteStruct(qv)
+}
+
+// DropQuarantined hides the quarantined valuation; it stays stored so the
+// same price is not flagged again on the next update
+func (db *DB) DropQuarantined(id string) error {
+	qv, err := db.getQuarantined(id)
+	if err != nil {
+		return err
+	}
+
+	qv.Dropped = true
+
+	return db.DB().Save(qv)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuspiciousValuation(t *testing.T) {
+	db := newTestDB(t, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	isin := &ISIN{ID: "IE00B4L5Y983"}
+	prev := &Valuation{Date: time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC), Open: 88, Source: DataSourceFT}
+
+	tests := []struct {
+		name       string
+		prev       *Valuation
+		value      float64
+		source     string
+		suspicious bool
+	}{
+		{"normal change", prev, 89, DataSourceFT, false},
+		{"change at the limit", prev, 132, DataSourceFT, false},
+		{"crash", prev, 40, DataSourceFT, true},
+		{"100x", prev, 8800, DataSourceFT, true},
+		{"1/100x", prev, 0.88, DataSourceFT, true},
+		{"zero", prev, 0, DataSourceFT, true},
+		{"negative manual price", prev, -1, DataSourceManual, true},
+		{"100x manual price", prev, 8800, DataSourceManual, false},
+		{"no previous price", nil, 8800, DataSourceFT, false},
+	}
+
+	for _, tt := range tests {
+		val := &Valuation{Date: time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), Open: tt.value, Source: tt.source}
+
+		reason := db.suspiciousValuation(isin, tt.prev, val)
+		if (reason != "") != tt.suspicious {
+			t.Errorf("%s: reason is '%s', want suspicious %v", tt.name, reason, tt.suspicious)
+		}
+	}
+}
+
+func TestValidateValuations(t *testing.T) {
+	tests := []struct {
+		name        string
+		batches     [][]float64
+		stored      []float64
+		quarantined int
+	}{
+		{"steady", [][]float64{{100, 101, 102, 103}}, []float64{100, 101, 102, 103}, 0},
+		{"isolated spike", [][]float64{{100, 101, 10100, 102}}, []float64{100, 101, 102}, 1},
+		{"100x for two days", [][]float64{{100, 101, 10100, 10200, 102}}, []float64{100, 101, 102}, 2},
+		{"level shift", [][]float64{{100, 101, 40, 41, 40.5}}, []float64{100, 101, 40, 41, 40.5}, 0},
+		{"level shift over updates", [][]float64{{100, 101}, {40}, {41}, {40.5, 40}}, []float64{100, 101, 40, 41, 40.5, 40}, 0},
+		{"disagreeing outliers", [][]float64{{100, 101, 40, 400, 4000}}, []float64{100, 101}, 3},
+	}
+
+	for _, tt := range tests {
+		db := newTestDB(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC))
+		isin := &ISIN{ID: "IE00B4L5Y983"}
+
+		if err := db.DB().Save(isin); err != nil {
+			t.Fatal(err)
+		}
+
+		day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+		for _, batch := range tt.batches {
+			var vals []*Valuation
+
+			for _, v := range batch {
+				vals = append(vals, &Valuation{ISIN: isin.ID, Date: day, Open: v, Source: DataSourceFT})
+				day = day.AddDate(0, 0, 1)
+			}
+
+			if err := db.ImportValuations(isin, vals); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stored := storedValuations(t, db, isin.ID)
+
+		var got []float64
+		for _, v := range stored {
+			got = append(got, v.Open)
+		}
+
+		if len(got) != len(tt.stored) {
+			t.Errorf("%s: stored %v, want %v", tt.name, got, tt.stored)
+		} else {
+			for k := range got {
+				if got[k] != tt.stored[k] {
+					t.Errorf("%s: stored %v, want %v", tt.name, got, tt.stored)
+
+					break
+				}
+			}
+		}
+
+		quarantine, err := db.GetQuarantine()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(quarantine) != tt.quarantined {
+			t.Errorf("%s: %d prices quarantined, want %d: %+v", tt.name, len(quarantine), tt.quarantined, quarantine)
+		}
+	}
+}
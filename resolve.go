@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/asdine/storm/v3"
+	"github.com/olekukonko/tablewriter"
+)
+
+var ErrInvalidPick = errors.New("invalid choice")
+
+// ResolveISIN turns the identifier into an ISIN and the listing to track:
+// tracked ISINs are returned as is, other identifiers and ISINs are searched
+// in the sources and the listings filtered on the exchange and currency of
+// the options. When several listings remain, the pick (1-based) chooses one,
+// or the user is asked when running interactively. Sources without search
+// accept any identifier, and cryptocurrencies need no lookup. The ISIN of a
+// listing found without one is looked up at its source. The listing is nil
+// unless a search found one
+func (a *App) ResolveISIN(ctx context.Context, id string, opts ISINOptions, pick int) (string, *Listing, error) {
+	if IsCrypto(id) {
+		return strings.ToUpper(id), nil, nil
+	}
+
+	if _, err := a.DB().GetISIN(id); err == nil {
+		return id, nil, nil
+	} else if !errors.Is(err, storm.ErrNotFound) {
+		return "", nil, err
+	}
+
+	listings, err := a.candidateListings(ctx, id, opts)
+	if err != nil || len(listings) == 0 {
+		return id, nil, err
+	}
+
+	l, err := a.pickListing(id, listings, pick)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if l.ISIN == "" {
+		if l.ISIN, err = a.DB().ListingISIN(ctx, l); err != nil {
+			return "", nil, err
+		}
+	}
+
+	a.logger.Infof("Resolved '%s' to '%s' (%s, exchange '%s', currency '%s')", id, l.ISIN, l.Name, l.Exchange, l.Currency)
+
+	return l.ISIN, l, nil
+}
+
+// candidateListings returns the listings of the identifier that match the
+// exchange and currency of the options
+func (a *App) candidateListings(ctx context.Context, id string, opts ISINOptions) ([]Listing, error) {
+	isISIN := LooksLikeISIN(id)
+
+	if isISIN {
+		if err := ValidateISIN(id); err != nil {
+			return nil, err
+		}
+	}
+
+	listings, err := a.DB().ResolveIdentifier(ctx, id, opts.Sources)
+	if isISIN && errors.Is(err, ErrNotResolved) {
+		// Let the sources decide whether they know it
+		return nil, nil
+	}
+
+	if err != nil || len(listings) == 0 {
+		return nil, err
+	}
+
+	matches := matchListings(listings, opts.Exchange, opts.Currency)
+	if len(matches) == 0 {
+		a.ShowListings(a.TableFormat(""), listings)
+
+		return nil, fmt.Errorf("%w: '%s' (exchange '%s', currency '%s')", ErrNoListing, id, opts.Exchange, opts.Currency)
+	}
+
+	return matches, nil
+}
+
+// pickListing returns the chosen listing; an ISIN that every source found
+// once is the same security, so the listing of the first source is taken
+func (a *App) pickListing(id string, listings []Listing, pick int) (*Listing, error) {
+	if pick == 0 && (len(listings) == 1 || (LooksLikeISIN(id) && oneListingPerSource(listings))) {
+		return &listings[0], nil
+	}
+
+	if pick == 0 {
+		a.ShowListings(a.TableFormat(""), listings)
+
+		if !isInteractive() {
+			return nil, fmt.Errorf("%w: '%s'", ErrAmbiguousListing, id)
+		}
+
+		fmt.Printf("Choose a listing for '%s' [1-%d]: ", id, len(listings))
+
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		pick, err = strconv.Atoi(strings.TrimSpace(line))
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidPick, strings.TrimSpace(line))
+		}
+	}
+
+	if pick < 1 || pick > len(listings) {
+		return nil, fmt.Errorf("%w: %d (expected 1-%d)", ErrInvalidPick, pick, len(listings))
+	}
+
+	return &listings[pick-1], nil
+}
+
+// oneListingPerSource reports whether no source found several listings
+func oneListingPerSource(listings []Listing) bool {
+	seen := map[string]bool{}
+
+	for _, l := range listings {
+		if seen[l.Source] {
+			return false
+		}
+
+		seen[l.Source] = true
+	}
+
+	return true
+}
+
+// ShowListings prints the listings, numbered for --pick
+func (a *App) ShowListings(tableFormat string, listings []Listing) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"#", "ISIN", "Name", "Symbol", "Exchange", "Currency", "Source"})
+	configureRenderer(table, tableFormat)
+
+	for n, l := range listings {
+		table.Append([]string{strconv.Itoa(n + 1), l.ISIN, l.Name, l.Symbol, l.Exchange, l.Currency, l.Source})
+	}
+
+	table.Render()
+}
+
+func isInteractive() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
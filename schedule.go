@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidTimeOfDay = errors.New("invalid time of day")
+	ErrInvalidWeekday   = errors.New("invalid weekday")
+	ErrEmptySchedule    = errors.New("schedule has no times or days")
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Schedule describes at which times of day, on which weekdays, updates run
+type Schedule struct {
+	Times    []time.Duration
+	Days     map[time.Weekday]bool
+	Location *time.Location
+}
+
+// NewSchedule parses times ("18:30"), days ("mon-fri", "sat" or "sun,wed")
+// and a time zone name into a schedule
+func NewSchedule(times []string, days []string, zone string) (*Schedule, error) {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Schedule{
+		Days:     map[time.Weekday]bool{},
+		Location: loc,
+	}
+
+	for _, t := range times {
+		parsed, err := time.Parse("15:04", t)
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidTimeOfDay, t)
+		}
+
+		s.Times = append(s.Times, time.Duration(parsed.Hour())*time.Hour+time.Duration(parsed.Minute())*time.Minute)
+	}
+
+	for _, d := range days {
+		if err := s.addDays(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.Times) == 0 || len(s.Days) == 0 {
+		return nil, ErrEmptySchedule
+	}
+
+	return &s, nil
+}
+
+func (s *Schedule) addDays(d string) error {
+	for _, part := range strings.Split(strings.ToLower(d), ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return fmt.Errorf("%w: '%s'", ErrInvalidWeekday, bounds[0])
+		}
+
+		last := first
+
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return fmt.Errorf("%w: '%s'", ErrInvalidWeekday, bounds[1])
+			}
+		}
+
+		for wd := first; ; wd = (wd + 1) % 7 {
+			s.Days[wd] = true
+
+			if wd == last {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// Next returns the first scheduled moment after t
+func (s *Schedule) Next(t time.Time) time.Time {
+	local := t.In(s.Location)
+	y, m, d := local.Date()
+
+	var next time.Time
+
+	for offset := 0; offset <= 7; offset++ {
+		day := time.Date(y, m, d+offset, 0, 0, 0, 0, s.Location)
+		if !s.Days[day.Weekday()] {
+			continue
+		}
+
+		for _, tod := range s.Times {
+			// Build the wall clock time, so DST changes do not shift it
+			candidate := time.Date(y, m, d+offset, int(tod/time.Hour), int(tod%time.Hour/time.Minute), 0, 0, s.Location)
+			if !candidate.After(t) {
+				continue
+			}
+
+			if next.IsZero() || candidate.Before(next) {
+				next = candidate
+			}
+		}
+
+		if !next.IsZero() {
+			return next
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/asdine/storm/v3"
+)
+
+// SourceStats counts the update runs per data source; it is persisted so the
+// metrics exporter can report on updates done by other processes
+type SourceStats struct {
+	Source      string `storm:"id"`
+	Success     uint64
+	Failure     uint64
+	LastSuccess time.Time
+	LastFailure time.Time
+}
+
+func (db *DB) RecordUpdateRun(source string, success bool) error {
+	db.statsLock.Lock()
+	defer db.statsLock.Unlock()
+
+	var s SourceStats
+
+	if err := db.DB().One("Source", source, &s); err != nil {
+		if !errors.Is(err, storm.ErrNotFound) {
+			return err
+		}
+
+		s.Source = source
+	}
+
+	if success {
+		s.Success++
+		s.LastSuccess = time.Now()
+	} else {
+		s.Failure++
+		s.LastFailure = time.Now()
+	}
+
+	return db.DB().Save(&s)
+}
+
+func (db *DB) GetAllSourceStats() ([]SourceStats, error) {
+	var stats []SourceStats
+
+	err := db.DB().All(&stats)
+
+	return stats, err
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Tax categories of an ISIN, which decide the TOB rate
+const (
+	TaxCategoryShare     = "share"
+	TaxCategoryBond      = "bond"
+	TaxCategoryETFEEA    = "etf-eea"
+	TaxCategoryETF       = "etf"
+	TaxCategoryFundDist  = "fund-distributing"
+	TaxCategoryFundAcc   = "fund-accumulating"
+	TaxCategoryTOBExempt = "exempt"
+
+	taxCurrency = "EUR"
+)
+
+var (
+	ErrUnknownTaxCategory = errors.New("unknown tax category")
+	ErrBondPercentage     = errors.New("bond percentage must be between 0 and 100")
+)
+
+// TOBEntry is the stock exchange tax owed on a transaction
+type TOBEntry struct {
+	Date     time.Time
+	ISIN     string
+	Category string
+	Sell     bool
+	Value    float64
+	Rate     float64
+	Tax      float64
+}
+
+// ReyndersEntry is the Reynders tax on a sale of a fund with bonds; the
+// amounts are in EUR
+type ReyndersEntry struct {
+	Date           time.Time
+	ISIN           string
+	Shares         float64
+	Proceeds       float64
+	Cost           float64
+	BondPercentage float64
+	Tax            float64
+}
+
+func (r *ReyndersEntry) Gain() float64 {
+	return r.Proceeds - r.Cost
+}
+
+func (c *TaxBEConfig) ValidateCategory(category string) error {
+	if _, ok := c.TOB[category]; ok {
+		return nil
+	}
+
+	categories := make([]string, 0, len(c.TOB))
+	for k := range c.TOB {
+		categories = append(categories, k)
+	}
+
+	sort.Strings(categories)
+
+	return fmt.Errorf("%w: '%s' (expected one of %s)", ErrUnknownTaxCategory, category, strings.Join(categories, ", "))
+}
+
+// SetTaxInfo sets the tax category and the percentage of bonds of the ISIN;
+// empty values keep the current setting
+func (db *DB) SetTaxInfo(isinID string, category string, bondPercentage *float64) error {
+	isin, err := db.GetISIN(isinID)
+	if err != nil {
+		return err
+	}
+
+	if category != "" {
+		if err := db.config.TaxBE.ValidateCategory(category); err != nil {
+			return err
+		}
+
+		isin.TaxCategory = category
+	}
+
+	if bondPercentage != nil {
+		if *bondPercentage < 0 || *bondPercentage > 100 {
+			return fmt.Errorf("%w: %g", ErrBondPercentage, *bondPercentage)
+		}
+
+		isin.BondPercentage = *bondPercentage
+	}
+
+	return db.DB().Save(isin)
+}
+
+// toEUR converts the amount in the nomination of the ISIN to EUR at the date
+func (db *DB) toEUR(isin *ISIN, amount float64, d time.Time) (float64, error) {
+	if isin.Nomination == "" {
+		return amount, nil
+	}
+
+	v, err := db.Convert(amount, isin.Nomination, taxCurrency, d)
+	if err != nil {
+		return 0, fmt.Errorf("%w (run 'fx update' or 'fx set')", err)
+	}
+
+	return v, nil
+}
+
+// TOB returns the stock exchange tax on the trades of the ISIN; ISINs without
+// a tax category are skipped
+func (db *DB) TOB(isin *ISIN) ([]TOBEntry, error) {
+	if isin.TaxCategory == "" {
+		return nil, nil
+	}
+
+	rate := db.config.TaxBE.TOB[isin.TaxCategory]
+
+	transactions, err := db.GetTransactionsForISIN(isin.ID)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	var result []TOBEntry
+
+	for _, t := range transactions {
+		if t.IsDividend() {
+			continue
+		}
+
+		e := TOBEntry{
+			Date:     t.Date,
+			ISIN:     isin.ID,
+			Category: isin.TaxCategory,
+			Sell:     t.TotalShares < 0,
+			Rate:     rate.Rate,
+		}
+
+		e.Value, err = db.toEUR(isin, math.Abs(t.TotalValue), t.Date)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Sell || !rate.SellOnly {
+			e.Tax = e.Value * rate.Rate / 100
+		} else {
+			e.Rate = 0
+		}
+
+		if rate.Max > 0 && e.Tax > rate.Max {
+			e.Tax = rate.Max
+		}
+
+		result = append(result, e)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+
+	return result, nil
+}
+
+// Reynders returns the Reynders tax on the sales of the ISIN: the gain of
+// every sale, weighed by the share of bonds, when that share exceeds the
+// threshold; losses are not taxed
+func (db *DB) Reynders(isin *ISIN) ([]ReyndersEntry, error) {
+	if isin.BondPercentage <= db.config.TaxBE.ReyndersThreshold {
+		return nil, nil
+	}
+
+	disposals, _, err := db.MatchLots(isin.ID, db.config.LotMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	bySale := map[time.Time]*ReyndersEntry{}
+
+	var result []*ReyndersEntry
+
+	for _, d := range disposals {
+		e, ok := bySale[d.Disposed]
+		if !ok {
+			e = &ReyndersEntry{Date: d.Disposed, ISIN: isin.ID, BondPercentage: isin.BondPercentage}
+			bySale[d.Disposed] = e
+			result = append(result, e)
+		}
+
+		proceeds, err := db.toEUR(isin, d.Proceeds, d.Disposed)
+		if err != nil {
+			return nil, err
+		}
+
+		cost, err := db.toEUR(isin, d.Cost, d.Acquired)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Shares += d.Shares
+		e.Proceeds += proceeds
+		e.Cost += cost
+	}
+
+	entries := make([]ReyndersEntry, 0, len(result))
+
+	for _, e := range result {
+		e.Tax = db.reyndersTax(e.Gain(), e.BondPercentage)
+		entries = append(entries, *e)
+	}
+
+	return entries, nil
+}
+
+func (db *DB) reyndersTax(gain, bondPercentage float64) float64 {
+	if gain <= 0 {
+		return 0
+	}
+
+	return gain * bondPercentage / 100 * db.config.TaxBE.ReyndersRate / 100
+}
+
+// ReyndersEstimate returns the Reynders tax that would be due when selling
+// all shares of the ISIN still held at the last known price
+func (db *DB) ReyndersEstimate(isin *ISIN) (*ReyndersEntry, error) {
+	if isin.BondPercentage <= db.config.TaxBE.ReyndersThreshold {
+		return nil, nil
+	}
+
+	_, lots, err := db.MatchLots(isin.ID, db.config.LotMethod)
+	if err != nil || len(lots) == 0 {
+		return nil, err
+	}
+
+	e := ReyndersEntry{Date: isin.UpdatedAt, ISIN: isin.ID, BondPercentage: isin.BondPercentage}
+
+	for _, l := range lots {
+		cost, err := db.toEUR(isin, l.Cost, l.Acquired)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Shares += l.Shares
+		e.Cost += cost
+	}
+
+	e.Proceeds, err = db.toEUR(isin, isin.ValueAt(isin.ValuePerShare, e.Shares, isin.UpdatedAt), isin.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Tax = db.reyndersTax(e.Gain(), e.BondPercentage)
+
+	return &e, nil
+}
+
+// ShowTaxBE prints the TOB per transaction, the Reynders tax per sale and an
+// estimate for the shares still held, and the totals per year; a year other
+// than 0 limits the report to that year
+func (a *App) ShowTaxBE(tableFormat string, year int) error {
+	isins, err := a.DB().GetAllISIN()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(isins, func(i, j int) bool {
+		return isins[i].ID < isins[j].ID
+	})
+
+	var (
+		tob       []TOBEntry
+		reynders  []ReyndersEntry
+		estimates []ReyndersEntry
+	)
+
+	for k := range isins {
+		i := &isins[k]
+
+		t, err := a.DB().TOB(i)
+		if err != nil {
+			return err
+		}
+
+		r, err := a.DB().Reynders(i)
+		if err != nil {
+			return err
+		}
+
+		e, err := a.DB().ReyndersEstimate(i)
+		if err != nil {
+			return err
+		}
+
+		tob = append(tob, t...)
+		reynders = append(reynders, r...)
+
+		if e != nil {
+			estimates = append(estimates, *e)
+		}
+	}
+
+	inYear := func(d time.Time) bool {
+		return year == 0 || d.Year() == year
+	}
+
+	totals := map[int]*[2]float64{}
+	total := func(d time.Time) *[2]float64 {
+		if totals[d.Year()] == nil {
+			totals[d.Year()] = &[2]float64{}
+		}
+
+		return totals[d.Year()]
+	}
+
+	table := a.taxTable(tableFormat, "Date", "ISIN", "Category", "Type", "Value", "Rate", "TOB")
+
+	for _, e := range tob {
+		if !inYear(e.Date) {
+			continue
+		}
+
+		kind := "buy"
+		if e.Sell {
+			kind = "sell"
+		}
+
+		table.Append([]string{
+			timeToDate(&e.Date), e.ISIN, e.Category, kind, a.localize(taxCurrency, e.Value),
+			fmt.Sprintf("%.2f%%", e.Rate), a.localize(taxCurrency, e.Tax),
+		})
+
+		total(e.Date)[0] += e.Tax
+	}
+
+	table.Render()
+
+	table = a.taxTable(tableFormat, "Date", "ISIN", "Shares", "Proceeds", "Cost", "Gain", "Bonds", "Reynders")
+
+	for _, e := range reynders {
+		if !inYear(e.Date) {
+			continue
+		}
+
+		a.appendReynders(table, &e, timeToDate(&e.Date))
+
+		total(e.Date)[1] += e.Tax
+	}
+
+	for _, e := range estimates {
+		a.appendReynders(table, &e, "if sold now")
+	}
+
+	table.Render()
+
+	years := make([]int, 0, len(totals))
+	for y := range totals {
+		years = append(years, y)
+	}
+
+	sort.Ints(years)
+
+	table = a.taxTable(tableFormat, "Year", "TOB", "Reynders")
+
+	for _, y := range years {
+		table.Append([]string{strconv.Itoa(y), a.localize(taxCurrency, totals[y][0]), a.localize(taxCurrency, totals[y][1])})
+	}
+
+	table.Render()
+
+	return nil
+}
+
+func (a *App) taxTable(tableFormat string, header ...string) *tablewriter.Table {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	configureRenderer(table, tableFormat)
+
+	return table
+}
+
+func (a *App) appendReynders(table *tablewriter.Table, e *ReyndersEntry, date string) {
+	table.Append([]string{
+		date, e.ISIN, formatShares(e.ISIN, e.Shares, 2), a.localize(taxCurrency, e.Proceeds), a.localize(taxCurrency, e.Cost),
+		a.localize(taxCurrency, e.Gain()), fmt.Sprintf("%.0f%%", e.BondPercentage), a.localize(taxCurrency, e.Tax),
+	})
+}
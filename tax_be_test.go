@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTOB(t *testing.T) {
+	tests := []struct {
+		category string
+		shares   float64
+		value    float64
+		rate     float64
+		tax      float64
+	}{
+		{TaxCategoryShare, 10, 10000, 0.35, 35},
+		{TaxCategoryShare, 1000, 1000000, 0.35, 1600},
+		{TaxCategoryETFEEA, -1000, -2000000, 0.12, 1300},
+		{TaxCategoryETF, -10, -10000, 0.35, 35},
+		{TaxCategoryFundAcc, 10, 10000, 0, 0},
+		{TaxCategoryFundAcc, -100, -100000, 1.32, 1320},
+		{TaxCategoryFundAcc, -1000, -1000000, 1.32, 4000},
+		{TaxCategoryTOBExempt, 10, 10000, 0, 0},
+	}
+
+	for _, tt := range tests {
+		db := newTestDB(t, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+		isin := &ISIN{ID: "BE0000000001", TaxCategory: tt.category}
+
+		if err := db.DB().Save(isin); err != nil {
+			t.Fatal(err)
+		}
+
+		tx := Transaction{ISIN: isin.ID, Type: TransactionTypeTrade, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), TotalShares: tt.shares, TotalValue: tt.value}
+		if err := db.CreateTransaction(&tx); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := db.TOB(isin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("%s %g: got %d entries, want 1", tt.category, tt.value, len(entries))
+		}
+
+		e := entries[0]
+		if e.Sell != (tt.shares < 0) || e.Rate != tt.rate || !near(e.Tax, tt.tax) {
+			t.Errorf("%s %g: sell %v at %g%% is %g, want %g%% and %g", tt.category, tt.value, e.Sell, e.Rate, e.Tax, tt.rate, tt.tax)
+		}
+	}
+}
+
+func TestReynders(t *testing.T) {
+	tests := []struct {
+		name           string
+		bondPercentage float64
+		proceeds       float64
+		tax            float64
+		estimate       float64
+	}{
+		{"no bonds", 0, -700, 0, 0},
+		{"at the threshold", 10, -700, 0, 0},
+		{"above the threshold", 10.5, -700, 200 * 0.105 * 0.3, 250 * 0.105 * 0.3},
+		{"mostly bonds", 60, -700, 200 * 0.6 * 0.3, 250 * 0.6 * 0.3},
+		{"loss", 60, -400, 0, 250 * 0.6 * 0.3},
+	}
+
+	for _, tt := range tests {
+		db := newTestDB(t, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+		isin := &ISIN{
+			ID: "BE0000000001", BondPercentage: tt.bondPercentage,
+			ValuePerShare: 150, UpdatedAt: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		}
+
+		if err := db.DB().Save(isin); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tx := range []Transaction{
+			{ISIN: isin.ID, Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), TotalShares: 10, TotalValue: 1000},
+			{ISIN: isin.ID, Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), TotalShares: -5, TotalValue: tt.proceeds},
+		} {
+			tx := tx
+			tx.Type = TransactionTypeTrade
+
+			if err := db.CreateTransaction(&tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		entries, err := db.Reynders(isin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		taxed := tt.bondPercentage > 10
+
+		if !taxed && len(entries) != 0 {
+			t.Errorf("%s: got %+v, want no Reynders tax", tt.name, entries)
+		}
+
+		if taxed && (len(entries) != 1 || !near(entries[0].Tax, tt.tax)) {
+			t.Errorf("%s: got %+v, want a tax of %g", tt.name, entries, tt.tax)
+		}
+
+		estimate, err := db.ReyndersEstimate(isin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !taxed && estimate != nil {
+			t.Errorf("%s: estimate is %+v, want none", tt.name, estimate)
+		}
+
+		if taxed && (estimate == nil || !near(estimate.Tax, tt.estimate)) {
+			t.Errorf("%s: estimate is %+v, want a tax of %g", tt.name, estimate, tt.estimate)
+		}
+	}
+}
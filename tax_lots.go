@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// ShowTaxLots prints the disposals of every ISIN, matched with the method, in
+// the nomination of the ISIN; a year other than 0 limits the report to the
+// sales of that year. With a csv file, the disposals are written there too,
+// or only to stdout for '-'
+func (a *App) ShowTaxLots(tableFormat string, year int, method string, csvFile string) error {
+	if err := ValidateLotMethod(method); err != nil {
+		return err
+	}
+
+	isins, err := a.DB().GetAllISIN()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(isins, func(i, j int) bool {
+		return isins[i].ID < isins[j].ID
+	})
+
+	byID := map[string]*ISIN{}
+
+	var disposals []Disposal
+
+	for k := range isins {
+		i := &isins[k]
+		byID[i.ID] = i
+
+		d, _, err := a.DB().MatchLots(i.ID, method)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range d {
+			if year == 0 || e.Disposed.Year() == year {
+				disposals = append(disposals, e)
+			}
+		}
+	}
+
+	sort.SliceStable(disposals, func(i, j int) bool {
+		return disposals[i].Disposed.Before(disposals[j].Disposed)
+	})
+
+	if csvFile != "" {
+		if err := writeTaxLotsCSV(csvFile, byID, disposals); err != nil || csvFile == "-" {
+			return err
+		}
+	}
+
+	table := a.taxTable(tableFormat, "ISIN", "Name", "Acquired", "Disposed", "Days", "Shares", "Proceeds", "Cost", "Gain")
+	totals := map[string]*[3]float64{}
+
+	for k := range disposals {
+		d := &disposals[k]
+		i := byID[d.ISIN]
+		acquired, days := d.acquisition()
+
+		table.Append([]string{
+			d.ISIN, i.Name, acquired, timeToDate(&d.Disposed), days,
+			formatShares(d.ISIN, d.Shares, 4), a.localize(i.Nomination, d.Proceeds),
+			a.localize(i.Nomination, d.Cost), a.localize(i.Nomination, d.Gain()),
+		})
+
+		if totals[i.Nomination] == nil {
+			totals[i.Nomination] = &[3]float64{}
+		}
+
+		totals[i.Nomination][0] += d.Proceeds
+		totals[i.Nomination][1] += d.Cost
+		totals[i.Nomination][2] += d.Gain()
+	}
+
+	nominations := make([]string, 0, len(totals))
+	for n := range totals {
+		nominations = append(nominations, n)
+	}
+
+	sort.Strings(nominations)
+
+	for _, n := range nominations {
+		t := totals[n]
+		table.Append([]string{"total", n, "", "", "", "", a.localize(n, t[0]), a.localize(n, t[1]), a.localize(n, t[2])})
+	}
+
+	table.Render()
+
+	return nil
+}
+
+func writeTaxLotsCSV(file string, byID map[string]*ISIN, disposals []Disposal) error {
+	var out io.Writer = os.Stdout
+
+	if file != "-" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		out = f
+	}
+
+	w := csv.NewWriter(out)
+
+	if err := w.Write([]string{
+		"isin", "name", "nomination", "acquired", "disposed", "holding_days", "shares", "proceeds", "cost", "gain",
+	}); err != nil {
+		return err
+	}
+
+	for k := range disposals {
+		d := &disposals[k]
+		i := byID[d.ISIN]
+		acquired, days := d.acquisition()
+
+		if err := w.Write([]string{
+			d.ISIN, i.Name, i.Nomination, acquired, timeToDate(&d.Disposed),
+			days, strconv.FormatFloat(d.Shares, 'f', -1, 64),
+			strconv.FormatFloat(d.Proceeds, 'f', 2, 64), strconv.FormatFloat(d.Cost, 'f', 2, 64),
+			strconv.FormatFloat(d.Gain(), 'f', 2, 64),
+		}); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
@@ -1,19 +1,75 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/asdine/storm/v3"
 	"github.com/asdine/storm/v3/q"
 	"github.com/google/uuid"
 )
 
+const (
+	TransactionTypeTrade    = "trade"
+	TransactionTypeDividend = "dividend"
+)
+
+var (
+	ErrTransactionType   = errors.New("unknown transaction type")
+	ErrTransactionShares = errors.New("a trade needs a number of shares")
+	ErrDividendShares    = errors.New("a dividend does not change the number of shares")
+	ErrTransactionSign   = errors.New("the value of a trade must have the sign of its shares (negative for a sale)")
+)
+
+// Transaction is a trade of shares, or a dividend payout; the value of a
+// dividend is the gross amount, the tax the amount withheld at the source.
+// Sales have negative shares and a negative value, and the fee of a trade
+// comes on top of its value. Transactions with an account are paid from, or
+// into, that cash account
 type Transaction struct {
 	UUID        uuid.UUID `storm:"id"`
 	Date        time.Time `storm:"index"`
 	ISIN        string    `storm:"index"`
+	Type        string
 	TotalShares float64
 	TotalValue  float64
+	Tax         float64
+	Fee         float64
+	Account     string `storm:"index"`
+}
+
+// IsDividend reports whether the transaction is a dividend payout; older
+// transactions without a type are trades
+func (t *Transaction) IsDividend() bool {
+	return t.Type == TransactionTypeDividend
+}
+
+// Net returns the value of the transaction after tax
+func (t *Transaction) Net() float64 {
+	return t.TotalValue - t.Tax
+}
+
+func (t *Transaction) Validate() error {
+	switch t.Type {
+	case "", TransactionTypeTrade:
+		if t.TotalShares == 0 {
+			return ErrTransactionShares
+		}
+
+		if t.TotalValue != 0 && (t.TotalValue < 0) != (t.TotalShares < 0) {
+			return fmt.Errorf("%w: %g shares for %g", ErrTransactionSign, t.TotalShares, t.TotalValue)
+		}
+	case TransactionTypeDividend:
+		if t.TotalShares != 0 {
+			return ErrDividendShares
+		}
+	default:
+		return fmt.Errorf("%w: '%s' (expected %s or %s)", ErrTransactionType, t.Type, TransactionTypeTrade, TransactionTypeDividend)
+	}
+
+	return nil
 }
 
 func (t *Transaction) ValuePerShare() float64 {
@@ -42,6 +98,21 @@ func (db *DB) GetTransaction(d time.Time) (*Transaction, error) {
 	return &i, nil
 }
 
+// GetDividends returns all dividend payouts
+func (db *DB) GetDividends() ([]Transaction, error) {
+	var i []Transaction
+
+	query := db.DB().Select(
+		q.Eq("Type", TransactionTypeDividend),
+	).OrderBy("Date")
+
+	if err := query.Find(&i); err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
 func (db *DB) GetTransactionsForISIN(isin string) ([]Transaction, error) {
 	var i []Transaction
 
@@ -56,18 +127,53 @@ func (db *DB) GetTransactionsForISIN(isin string) ([]Transaction, error) {
 	return i, nil
 }
 
+// GetFirstTransactionDate returns the date of the oldest transaction of the ISIN
+func (db *DB) GetFirstTransactionDate(isin string) (time.Time, error) {
+	var t Transaction
+
+	query := db.DB().Select(
+		q.Eq("ISIN", isin),
+	).OrderBy("Date").Limit(1)
+
+	if err := query.First(&t); err != nil {
+		return time.Time{}, err
+	}
+
+	return t.Date, nil
+}
+
 func (db *DB) CreateTransaction(t *Transaction) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	if t.Account != "" {
+		if _, err := db.GetCashAccount(t.Account); err != nil {
+			return fmt.Errorf("%w: cash account '%s'", err, t.Account)
+		}
+	}
+
 	t.GenerateUUID()
 
 	return db.DB().Save(t)
 }
 
 func (t *Transaction) String() string {
+	if t.IsDividend() {
+		return fmt.Sprintf(
+			"ISIN: '%v'; dividend: %.2f, tax: %.2f, date: %s",
+			t.ISIN,
+			t.TotalValue,
+			t.Tax,
+			timeToDate(&t.Date),
+		)
+	}
+
 	return fmt.Sprintf(
-		"ISIN: '%v'; total value: %.2f, shares: '%.2f', date: %s",
+		"ISIN: '%v'; total value: %.2f, shares: '%s', date: %s",
 		t.ISIN,
 		t.TotalValue,
-		t.TotalShares,
+		formatShares(t.ISIN, t.TotalShares, 2),
 		timeToDate(&t.Date),
 	)
 }
@@ -87,3 +193,99 @@ func (t *Transaction) SetDate(date string) error {
 
 	return nil
 }
+
+// CostBasis returns the average cost of the shares of the ISIN still held:
+// sales take their share of the cost out at the average price, and a sale of
+// more than is held closes the position. The cost of an ISIN merged into this
+// one moves over on the date of the merger
+func (db *DB) CostBasis(isin string) (float64, error) {
+	_, cost, err := db.costBasis(isin, endOfTime)
+
+	return cost, err
+}
+
+// costBasis returns the shares and their cost up to the date
+func (db *DB) costBasis(isin string, until time.Time) (float64, float64, error) {
+	transactions, err := db.GetTransactionsForISIN(isin)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return 0, 0, err
+	}
+
+	actions, err := db.corporateActions("ISIN", isin)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if merged, gone := mergedAway(actions, until); gone {
+		if until.Equal(endOfTime) {
+			return 0, 0, nil
+		}
+
+		until = merged.Add(-time.Nanosecond)
+	}
+
+	mergers, err := db.mergersInto(isin, until)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].Date.Before(transactions[j].Date)
+	})
+
+	var shares, cost float64
+
+	join := func(m *CorporateAction) error {
+		s, c, err := db.costBasis(m.ISIN, m.Date.Add(-time.Nanosecond))
+		if err != nil {
+			return err
+		}
+
+		shares += s * m.Ratio * splitFactor(actions, m.Date)
+		cost += c
+
+		return nil
+	}
+
+	for _, t := range transactions {
+		if t.Date.After(until) {
+			break
+		}
+
+		for ; len(mergers) > 0 && !t.Date.Before(mergers[0].Date); mergers = mergers[1:] {
+			if err := join(&mergers[0]); err != nil {
+				return 0, 0, err
+			}
+		}
+
+		if t.IsDividend() {
+			continue
+		}
+
+		s := t.TotalShares * splitFactor(actions, t.Date)
+
+		switch {
+		case s > 0:
+			cost += t.TotalValue
+		case shares+s <= 1e-9:
+			// The position is closed; shares sold beyond it have no cost
+			if shares+s < -1e-9 {
+				db.logger.Warnf("Sale of '%s' at %s exceeds the shares bought, assuming no cost", isin, timeToDate(&t.Date))
+			}
+
+			cost, s = 0, -shares
+		default:
+			cost *= (shares + s) / shares
+		}
+
+		shares += s
+	}
+
+	for k := range mergers {
+		if err := join(&mergers[k]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return shares, cost, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/remeh/sizedwaitgroup"
+)
+
+const (
+	FailOnAny  = "any"
+	FailOnAll  = "all"
+	FailOnNone = "none"
+)
+
+var (
+	ErrUpdateFailed  = errors.New("update failed")
+	ErrUpdateSkipped = errors.New("update not started")
+	ErrUnknownFailOn = errors.New("unknown fail-on policy")
+)
+
+// UpdateResult is the outcome of updating a single ISIN
+type UpdateResult struct {
+	ISIN   string
+	Name   string
+	Source string
+	Added  int
+	Latest time.Time
+	Err    error
+}
+
+// UpdateResults are the outcomes of an update run
+type UpdateResults []UpdateResult
+
+// Failed returns the number of ISINs whose update failed; skipped ones do
+// not count
+func (r UpdateResults) Failed() int {
+	failed := 0
+
+	for _, u := range r {
+		if u.Err != nil && !errors.Is(u.Err, ErrUpdateSkipped) {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// Skipped returns the number of ISINs whose update was not started
+func (r UpdateResults) Skipped() int {
+	skipped := 0
+
+	for _, u := range r {
+		if errors.Is(u.Err, ErrUpdateSkipped) {
+			skipped++
+		}
+	}
+
+	return skipped
+}
+
+func (r UpdateResults) Added() int {
+	added := 0
+
+	for _, u := range r {
+		added += u.Added
+	}
+
+	return added
+}
+
+func ValidateFailOn(failOn string) error {
+	switch failOn {
+	case FailOnAny, FailOnAll, FailOnNone:
+		return nil
+	default:
+		return fmt.Errorf("%w: '%s'", ErrUnknownFailOn, failOn)
+	}
+}
+
+// Check returns an error when the failures violate the policy
+func (r UpdateResults) Check(failOn string) error {
+	if err := ValidateFailOn(failOn); err != nil {
+		return err
+	}
+
+	failed := r.Failed()
+	attempted := len(r) - r.Skipped()
+
+	switch failOn {
+	case FailOnNone:
+		return nil
+	case FailOnAny:
+		if failed == 0 {
+			return nil
+		}
+	case FailOnAll:
+		if failed < attempted || attempted == 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %d of %d ISINs failed", ErrUpdateFailed, failed, attempted)
+}
+
+func (db *DB) UpdateValuationsAll(ctx context.Context) (UpdateResults, error) {
+	return db.UpdateValuationsUntil(ctx, ctx)
+}
+
+// UpdateValuationsUntil updates all ISINs, but starts no new ones once the
+// starting context is done; ISINs already being updated only stop with ctx
+func (db *DB) UpdateValuationsUntil(ctx context.Context, starting context.Context) (UpdateResults, error) {
+	isins, err := db.GetAllISIN()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(UpdateResults, len(isins))
+	start := time.Now()
+	swg := sizedwaitgroup.New(db.config.Concurrency)
+
+	for isin := range isins {
+		swg.Add()
+
+		if err := starting.Err(); err != nil {
+			results[isin] = UpdateResult{ISIN: isins[isin].ID, Name: isins[isin].Name, Err: fmt.Errorf("%w: %v", ErrUpdateSkipped, err)}
+
+			swg.Done()
+
+			continue
+		}
+
+		go func(i *ISIN, r *UpdateResult) {
+			defer swg.Done()
+			db.logger.Infof("Updating ISIN: %s", i.ID)
+
+			*r = db.updateISIN(ctx, i)
+		}(&isins[isin], &results[isin])
+	}
+
+	swg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ISIN < results[j].ISIN
+	})
+
+	db.logger.Infof(
+		"Updated %d ISINs in %s: %d failed, %d skipped, %d new valuations",
+		len(results), time.Since(start).Round(time.Millisecond), results.Failed(), results.Skipped(), results.Added(),
+	)
+
+	return results, nil
+}
+
+func (db *DB) updateISIN(ctx context.Context, i *ISIN) UpdateResult {
+	defer db.SendAlerts(ctx)
+
+	before, _ := db.CountValuations(i.ID)
+
+	source, err := db.updateFromChain(ctx, i)
+	if err != nil {
+		db.logger.Errorf("Error updating ISIN '%s': %v", i.ID, err)
+	}
+
+	after, _ := db.CountValuations(i.ID)
+
+	return UpdateResult{
+		ISIN:   i.ID,
+		Name:   i.Name,
+		Source: source,
+		Added:  after - before,
+		Latest: i.UpdatedAt,
+		Err:    err,
+	}
+}
+
+func (a *App) ShowUpdateResults(tableFormat string, results UpdateResults) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ISIN", "Name", "Source", "New valuations", "Latest", "Error"})
+	configureRenderer(table, tableFormat)
+
+	for _, r := range results {
+		latest := ""
+		if !r.Latest.IsZero() {
+			latest = timeToDate(&r.Latest)
+		}
+
+		errMsg := ""
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+
+		table.Append([]string{r.ISIN, r.Name, r.Source, fmt.Sprintf("%d", r.Added), latest, errMsg})
+	}
+
+	table.Append([]string{
+		"Total", "", "", fmt.Sprintf("%d", results.Added()), "",
+		fmt.Sprintf("%d of %d failed, %d skipped", results.Failed(), len(results), results.Skipped()),
+	})
+
+	table.Render()
+}
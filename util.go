@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"time"
 )
 
@@ -9,3 +10,41 @@ func timeToDate(t *time.Time) string {
 	y, m, d := t.UTC().Date()
 	return fmt.Sprintf("%04d-%02d-%02d", y, m, d)
 }
+
+// addMonths adds the months to the date; the day is clamped to the end of
+// shorter months, so a month after January 31 is February 28 (or 29)
+func addMonths(d time.Time, months int) time.Time {
+	first := time.Date(d.Year(), d.Month()+time.Month(months), 1, d.Hour(), d.Minute(), d.Second(), d.Nanosecond(), d.Location())
+
+	day := d.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
+
+// daysBetween returns the number of (partial) days between from and to,
+// including the day of from itself
+func daysBetween(from, to time.Time) int {
+	days := int(math.Ceil(to.Sub(from).Hours()/24)) + 1
+	if days < 1 {
+		return 1
+	}
+
+	return days
+}
+
+// businessDaysBetween returns the number of weekdays after from, up to and
+// including to
+func businessDaysBetween(from, to time.Time) int {
+	days := 0
+
+	for d := from.AddDate(0, 0, 1); !d.After(to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			days++
+		}
+	}
+
+	return days
+}
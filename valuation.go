@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/asdine/storm/v3"
 	"github.com/asdine/storm/v3/q"
 )
 
@@ -15,10 +18,51 @@ type Valuation struct {
 	High  float64
 	Low   float64
 	Close float64
+
+	Source string
+}
+
+const (
+	PriceFieldOpen    = "open"
+	PriceFieldClose   = "close"
+	PriceFieldHigh    = "high"
+	PriceFieldLow     = "low"
+	PriceFieldTypical = "typical"
+	PriceFieldMid     = "mid"
+)
+
+var (
+	ErrUnknownPriceField = errors.New("unknown price field")
+
+	PriceFields = []string{PriceFieldOpen, PriceFieldClose, PriceFieldHigh, PriceFieldLow, PriceFieldTypical, PriceFieldMid}
+)
+
+func ValidatePriceField(field string) error {
+	for _, f := range PriceFields {
+		if f == field {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: '%s' (expected one of %s)", ErrUnknownPriceField, field, strings.Join(PriceFields, ", "))
 }
 
-func (v *Valuation) Value() float64 {
-	return v.Open
+// Value returns the price of the valuation according to the field
+func (v *Valuation) Value(field string) float64 {
+	switch field {
+	case PriceFieldClose:
+		return v.Close
+	case PriceFieldHigh:
+		return v.High
+	case PriceFieldLow:
+		return v.Low
+	case PriceFieldTypical:
+		return (v.High + v.Low + v.Close) / 3
+	case PriceFieldMid:
+		return (v.High + v.Low) / 2
+	default:
+		return v.Open
+	}
 }
 
 func (db *DB) GetValuation(id string) (*Valuation, error) {
@@ -34,11 +78,21 @@ func (db *DB) GetValuation(id string) (*Valuation, error) {
 		return nil, err
 	}
 
+	if err := db.adjustValuation(&i); err != nil {
+		return nil, err
+	}
+
 	db.logger.Debugf("Valuation for '%s': %#v", id, i)
 
 	return &i, nil
 }
 
+func (db *DB) CountValuations(isin string) (int, error) {
+	return db.DB().Select(
+		q.Eq("ISIN", isin),
+	).Count(&Valuation{})
+}
+
 func (db *DB) GetValuationAt(isin string, d time.Time) (*Valuation, error) {
 	var v Valuation
 
@@ -51,6 +105,30 @@ func (db *DB) GetValuationAt(isin string, d time.Time) (*Valuation, error) {
 		return nil, err
 	}
 
+	if err := db.adjustValuation(&v); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// GetValuationBefore returns the last valuation strictly before the date
+func (db *DB) GetValuationBefore(isin string, d time.Time) (*Valuation, error) {
+	var v Valuation
+
+	query := db.DB().Select(
+		q.Eq("ISIN", isin),
+		q.Lt("Date", d),
+	).Reverse().OrderBy("Date").Limit(1)
+
+	if err := query.First(&v); err != nil {
+		return nil, err
+	}
+
+	if err := db.adjustValuation(&v); err != nil {
+		return nil, err
+	}
+
 	return &v, nil
 }
 
@@ -62,17 +140,11 @@ func (db *DB) GetSharesAt(isin string, d time.Time) (float64, error) {
 		q.Lte("Date", d),
 	).Reverse().OrderBy("Date")
 
-	if err := query.Find(&transactions); err != nil {
+	if err := query.Find(&transactions); err != nil && !errors.Is(err, storm.ErrNotFound) {
 		return 0, err
 	}
 
-	var v float64
-
-	for _, tx := range transactions {
-		v += tx.TotalShares
-	}
-
-	return v, nil
+	return db.sharesAt(isin, transactions, d)
 }
 
 func (v *Valuation) UpdateID() {